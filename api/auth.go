@@ -8,6 +8,7 @@ import (
 	"io"
 	"l7-cloud-builder/config"
 	"l7-cloud-builder/logger"
+	"l7-cloud-builder/secrets"
 	"net/http"
 )
 
@@ -77,6 +78,7 @@ func Login(ctx context.Context) (err error) {
 	if _, ok := v["data"]; ok {
 		// Use the data key as the token
 		config.Api.Token = v["data"]
+		secrets.Register(config.Api.Token)
 		return nil
 	} else if _, ok := v["message"]; ok {
 		// Return an error with the message
@@ -2,15 +2,20 @@ package api
 
 import (
 	"context"
-	sm "dev.hackerman.me/artheon/veverse-shared/model"
 	"encoding/json"
 	"fmt"
 	"io"
 	"l7-cloud-builder/config"
 	"l7-cloud-builder/logger"
 	"net/http"
+	"strings"
 )
 
+// sharedConfigurationETag is the ETag of the last applied shared configuration, sent with the next request to skip unchanged configuration
+var sharedConfigurationETag string
+
+// LoadSharedConfiguration fetches the shared configuration from the API and applies it if it has changed since the last
+// load. The configuration is applied on top of the defaults and replaced atomically, the changes are logged as a diff.
 func LoadSharedConfiguration(ctx context.Context) error {
 	// Login to the API
 	err := Login(ctx)
@@ -30,6 +35,9 @@ func LoadSharedConfiguration(ctx context.Context) error {
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.Api.Token))
+	if sharedConfigurationETag != "" {
+		req.Header.Set("If-None-Match", sharedConfigurationETag)
+	}
 
 	// Prepare the client
 	client := &http.Client{}
@@ -51,9 +59,14 @@ func LoadSharedConfiguration(ctx context.Context) error {
 		}
 	}(res.Body)
 
+	// Configuration has not changed since the last request
+	if res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
 	// Check the response status code
 	if res.StatusCode >= 400 {
-		return fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, err)
+		return fmt.Errorf("failed to fetch shared configuration from %s, status code: %d, error: %v", url, res.StatusCode, err)
 	}
 
 	// Prepare the response body
@@ -63,12 +76,13 @@ func LoadSharedConfiguration(ctx context.Context) error {
 		return err
 	}
 
-	// Prepare the job container
+	// Prepare the configuration container, the received configuration overrides the default values
 	var c struct {
-		Configuration sm.AutomationConfiguration `json:"data"` // todo: implement this
-		Status        string                     `json:"status"`
-		Message       string                     `json:"message"`
+		Configuration *config.SharedConfig `json:"data"`
+		Status        string               `json:"status"`
+		Message       string               `json:"message"`
 	}
+	c.Configuration = config.DefaultSharedConfiguration()
 	err = json.Unmarshal(resBody, &c)
 	if err != nil {
 		return err
@@ -76,11 +90,35 @@ func LoadSharedConfiguration(ctx context.Context) error {
 
 	// Handle error case
 	if c.Status == "error" {
-		return fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, c.Message)
+		return fmt.Errorf("failed to fetch shared configuration from %s, status code: %d, error: %v", url, res.StatusCode, c.Message)
+	}
+
+	// Handle missing configuration
+	if c.Configuration == nil {
+		return fmt.Errorf("failed to fetch shared configuration from %s, no configuration in response", url)
+	}
+
+	// Skip the configuration with the same version
+	current := config.SharedConfiguration()
+	if c.Configuration.Version != "" && c.Configuration.Version == current.Version {
+		sharedConfigurationETag = res.Header.Get("ETag")
+		return nil
+	}
+
+	// Log the changes
+	diff, err := config.DiffSharedConfiguration(current, c.Configuration)
+	if err != nil {
+		return fmt.Errorf("failed to compare shared configuration: %w", err)
+	}
+	if len(diff) == 0 {
+		sharedConfigurationETag = res.Header.Get("ETag")
+		return nil
 	}
+	logger.Logger.Infof("shared configuration changed, version %q -> %q:\n%s", current.Version, c.Configuration.Version, strings.Join(diff, "\n"))
 
-	config.Shared.Release.IgnoredFiles = c.Configuration.Release.IgnoredFiles // todo: implement this
+	// Apply the configuration
+	config.SetSharedConfiguration(c.Configuration)
+	sharedConfigurationETag = res.Header.Get("ETag")
 
-	// Return the job
 	return nil
 }
@@ -9,15 +9,20 @@ import (
 	"io"
 	"l7-cloud-builder/config"
 	"l7-cloud-builder/logger"
+	"l7-cloud-builder/secrets"
+	"l7-cloud-builder/unreal"
 	"net/http"
+	neturl "net/url"
 	"strings"
 )
 
-func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, error) {
+// FetchUnclaimedJob fetches an unclaimed job for the node, returns the job and the engine required by the job release,
+// empty if the release does not require one. The engine is not a field of the shared job model, it is decoded separately.
+func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, string, error) {
 	// Login to the API
 	err := Login(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Get the list of enabled platforms
@@ -47,10 +52,15 @@ func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, error) {
 	// Prepare the request URL
 	url := fmt.Sprintf("%s/job/v2/unclaimed?platform=%s&type=%s&target=%s", config.Api.Url, strings.Join(enabledPlatforms, ","), strings.Join(enabledJobs, ","), strings.Join(enabledTargets, ","))
 
+	// Advertise the installed engines, so the jobs requiring an engine are only fetched by the nodes having it
+	if engines := unreal.EngineTags(); len(engines) > 0 {
+		url += "&engine=" + neturl.QueryEscape(strings.Join(engines, ","))
+	}
+
 	// Prepare the request
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Set headers
@@ -66,7 +76,7 @@ func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, error) {
 	// Send the request
 	res, err = client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Defer closing the response body
@@ -79,14 +89,14 @@ func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, error) {
 
 	// Check the response status code
 	if res.StatusCode >= 400 {
-		return nil, fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, err)
+		return nil, "", fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, err)
 	}
 
 	// Prepare the response body
 	var resBody []byte
 	resBody, err = io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Prepare the job container
@@ -97,24 +107,51 @@ func FetchUnclaimedJob(ctx context.Context) (*sm.JobV2, error) {
 	}
 	err = json.Unmarshal(resBody, &c)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Handle no unclaimed jobs case
 	if c.Status == "no jobs" {
-		return nil, nil
+		return nil, "", nil
 	}
 
 	// Handle error case
 	if c.Status == "error" {
-		return nil, fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, c.Message)
+		return nil, "", fmt.Errorf("failed to fetch unclaimed job from %s, status code: %d, error: %v", url, res.StatusCode, c.Message)
+	}
+
+	// Decode the engine required by the job release, e.g. "5.1" or an engine identifier
+	var r struct {
+		Job struct {
+			Release *struct {
+				Engine string `json:"engine"`
+			} `json:"release"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(resBody, &r); err != nil {
+		return nil, "", err
+	}
+
+	releaseEngine := ""
+	if r.Job.Release != nil {
+		releaseEngine = r.Job.Release.Engine
 	}
 
 	// Return the job
-	return &c.Job, nil
+	return &c.Job, releaseEngine, nil
 }
 
 func UpdateJobStatus(ctx context.Context, job *sm.JobV2, status config.JobStatusType, message string) error {
+	return updateJobStatus(ctx, job, status, message, "")
+}
+
+// UpdateJobFailure updates the job status with the failure message and category, so failures of the build nodes can be
+// told apart from failures of the code or content.
+func UpdateJobFailure(ctx context.Context, job *sm.JobV2, status config.JobStatusType, message string, category config.FailureCategoryType) error {
+	return updateJobStatus(ctx, job, status, message, config.Config.FailureMapping[category])
+}
+
+func updateJobStatus(ctx context.Context, job *sm.JobV2, status config.JobStatusType, message string, failureCategory string) error {
 	if job == nil {
 		return fmt.Errorf("job is nil")
 	}
@@ -130,11 +167,13 @@ func UpdateJobStatus(ctx context.Context, job *sm.JobV2, status config.JobStatus
 
 	// Prepare the request body
 	body := struct {
-		Status  config.JobStatusType `json:"status"`
-		Message string               `json:"message"`
+		Status          config.JobStatusType `json:"status"`
+		Message         string               `json:"message"`
+		FailureCategory string               `json:"failureCategory,omitempty"`
 	}{
-		Status:  status,
-		Message: message,
+		Status:          status,
+		Message:         secrets.Redact(message),
+		FailureCategory: failureCategory,
 	}
 
 	// Marshal the body
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"io"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/secrets"
+	"l7-cloud-builder/unreal"
+	"net/http"
+	"os"
+)
+
+// UpdatePackageValidation posts the content validation findings to the package entity, so the creators get the errors
+// of their content before the package job.
+func UpdatePackageValidation(ctx context.Context, packageId uuid.UUID, jobId uuid.UUID, result *unreal.ValidationResult) error {
+	if packageId.IsNil() {
+		return fmt.Errorf("invalid package id")
+	}
+
+	if result == nil {
+		return fmt.Errorf("validation result is nil")
+	}
+
+	// Login to the API
+	err := Login(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the request URL
+	url := fmt.Sprintf("%s/packages/%s/validation", config.Api.Url, packageId)
+
+	// Prepare the request body, the secrets are redacted from the messages like from the job status
+	findings := make([]unreal.ValidationFinding, 0, len(result.Findings))
+	for _, f := range result.Findings {
+		f.Message = secrets.Redact(f.Message)
+		findings = append(findings, f)
+	}
+
+	body := struct {
+		JobId    uuid.UUID                  `json:"jobId"`
+		Errors   int                        `json:"errors"`
+		Warnings int                        `json:"warnings"`
+		Findings []unreal.ValidationFinding `json:"findings"`
+	}{
+		JobId:    jobId,
+		Errors:   result.Errors,
+		Warnings: result.Warnings,
+		Findings: findings,
+	}
+
+	// Marshal the body
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.Api.Token))
+
+	// Prepare the client
+	client := &http.Client{}
+
+	// Send the request
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Defer closing the response body
+	defer func(body io.ReadCloser) {
+		err := body.Close()
+		if err != nil {
+			logger.Logger.Warningf("error closing http response body: %v\n", err)
+		}
+	}(res.Body)
+
+	// Check the response status code
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("failed to update package validation, status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// DownloadPackageSource downloads the package source archive, the zip of the package content plugin directory, to the
+// file.
+func DownloadPackageSource(ctx context.Context, packageId uuid.UUID, path string) error {
+	if packageId.IsNil() {
+		return fmt.Errorf("invalid package id")
+	}
+
+	// Login to the API
+	err := Login(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the request URL
+	url := fmt.Sprintf("%s/packages/%s/source", config.Api.Url, packageId)
+
+	// Prepare the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Set headers
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.Api.Token))
+
+	// Prepare the client
+	client := &http.Client{}
+
+	// Send the request
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Defer closing the response body
+	defer func(body io.ReadCloser) {
+		err := body.Close()
+		if err != nil {
+			logger.Logger.Warningf("error closing http response body: %v\n", err)
+		}
+	}(res.Body)
+
+	// Check the response status code
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("failed to download package source, status code: %d", res.StatusCode)
+	}
+
+	// Stream the archive to the file, the packages may be large
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create package source file: %w", err)
+	}
+
+	if _, err = io.Copy(file, res.Body); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to download package source: %w", err)
+	}
+
+	return file.Close()
+}
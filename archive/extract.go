@@ -0,0 +1,77 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"l7-cloud-builder/logger"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractEntry writes the regular file entry to the path, the zip reader checks the CRC-32 at the end of the content.
+func extractEntry(f *zip.File, filePath string) error {
+	reader, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close zip entry: %v", err)
+		}
+	}(reader)
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// ExtractZipArchive extracts the regular files and the directories of the zip archive to the directory. The entries
+// escaping the directory and the symlinks are rejected, the archives may come from the users, e.g. the UGC packages.
+func ExtractZipArchive(archivePath string, dir string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer func(reader *zip.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close archive: %v", err)
+		}
+	}(reader)
+
+	root := filepath.Clean(dir)
+	for _, f := range reader.File {
+		target := filepath.Join(root, filepath.FromSlash(f.Name))
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("invalid archive %s: entry %s is outside the directory", archivePath, f.Name)
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, 0755)
+		case mode.IsRegular():
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = extractEntry(f, target)
+			}
+		default:
+			return fmt.Errorf("invalid archive %s: entry %s is not a regular file or a directory", archivePath, f.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s from %s: %w", f.Name, archivePath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"l7-cloud-builder/logger"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// expectedMode returns the Unix mode the file is archived with.
+func expectedMode(filePath string, info os.FileInfo, options ZipOptions) (os.FileMode, error) {
+	if info.Mode()&os.ModeSymlink != 0 || !options.Executables {
+		return entryMode(info, false, options), nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close file: %v", err)
+		}
+	}(file)
+
+	executable, err := isExecutable(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return entryMode(info, executable, options), nil
+}
+
+// copyEntry copies the archive entry content to the writer and returns the number of bytes copied, the zip reader checks
+// the CRC-32 at the end of the content.
+func copyEntry(f *zip.File, w io.Writer) (int64, error) {
+	reader, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer func(reader io.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close zip entry: %v", err)
+		}
+	}(reader)
+
+	return io.Copy(w, reader)
+}
+
+// verifyEntry checks the archive entry has the Unix mode and the size of the file, and the symlink entry points to the
+// same target inside the archive.
+func verifyEntry(f *zip.File, filePath string, options ZipOptions) error {
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return err
+	}
+
+	expected, err := expectedMode(filePath, info, options)
+	if err != nil {
+		return err
+	}
+
+	// The mode is read from the Unix external attributes
+	mode := f.Mode() & (os.ModeSymlink | os.ModePerm)
+	if mode != expected {
+		return fmt.Errorf("entry %s has mode %s, expected %s", f.Name, mode, expected)
+	}
+
+	if expected&os.ModeSymlink != 0 {
+		// Only the symlink targets are read into memory
+		var link bytes.Buffer
+		if _, err = copyEntry(f, &link); err != nil {
+			return fmt.Errorf("entry %s is corrupted: %w", f.Name, err)
+		}
+		content := link.String()
+
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return err
+		}
+
+		if content != filepath.ToSlash(target) {
+			return fmt.Errorf("symlink %s points to %s, expected %s", f.Name, content, filepath.ToSlash(target))
+		}
+
+		// The link must resolve inside the extracted archive
+		resolved := path.Join(path.Dir(f.Name), content)
+		if path.IsAbs(content) || filepath.IsAbs(target) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return fmt.Errorf("symlink %s points to %s outside the archive", f.Name, content)
+		}
+
+		return nil
+	}
+
+	// The regular files are streamed, the release paks are several gigabytes
+	size, err := copyEntry(f, io.Discard)
+	if err != nil {
+		return fmt.Errorf("entry %s is corrupted: %w", f.Name, err)
+	}
+
+	if size != info.Size() {
+		return fmt.Errorf("entry %s has %d bytes, expected %d", f.Name, size, info.Size())
+	}
+
+	return nil
+}
+
+// VerifyZipArchive reopens the zip archive and checks it has an entry for each of the files with the expected Unix mode
+// and size, the symlinks are stored as links pointing inside the archive, and the content matches the CRC-32.
+func VerifyZipArchive(output, basePath string, files []string, options ZipOptions) error {
+	reader, err := zip.OpenReader(output)
+	if err != nil {
+		return fmt.Errorf("invalid archive %s: %w", output, err)
+	}
+	defer func(reader *zip.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close zip file: %v", err)
+		}
+	}(reader)
+
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		if _, ok := entries[f.Name]; ok {
+			return fmt.Errorf("invalid archive %s: duplicate entry %s", output, f.Name)
+		}
+		entries[f.Name] = f
+	}
+
+	if len(entries) != len(files) {
+		return fmt.Errorf("invalid archive %s: %d entries, expected %d", output, len(entries), len(files))
+	}
+
+	for _, file := range files {
+		name := filepath.ToSlash(file)
+		f, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("invalid archive %s: missing entry %s", output, name)
+		}
+
+		if err = verifyEntry(f, filepath.Join(basePath, file), options); err != nil {
+			return fmt.Errorf("invalid archive %s: %w", output, err)
+		}
+	}
+
+	return nil
+}
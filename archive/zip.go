@@ -2,42 +2,162 @@ package archive
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"l7-cloud-builder/logger"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 )
 
+// ZipOptions are the options of the zip archive
+type ZipOptions struct {
+	Reproducible     bool      // Sort the entries, use the fixed timestamp, normalize the permissions and use the fixed compression level
+	ModTime          time.Time // Timestamp of the reproducible entries, e.g. SOURCE_DATE_EPOCH or the commit date, 1980-01-01 if zero
+	CompressionLevel int       // Deflate compression level of the reproducible archives, from 1 (fastest) to 9 (best)
+	Executables      bool      // Mark the ELF binaries and the shell scripts executable, e.g. for the Linux builds staged on Windows
+}
+
+// ZipInfo describes the created zip archive, recorded in the release manifest
+type ZipInfo struct {
+	Name         string     `json:"name"`
+	Files        int        `json:"files"`             // Number of the archived files
+	Size         int64      `json:"size"`              // Archive size in bytes
+	Sha256       string     `json:"sha256"`            // Archive SHA-256, identical for the identical inputs of the reproducible archives
+	Reproducible bool       `json:"reproducible"`      // The archive is reproducible
+	ModTime      *time.Time `json:"modTime,omitempty"` // Timestamp of the reproducible entries
+}
+
+// zipEpoch is the earliest time representable in the MS-DOS date format of the zip headers
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// isExecutable checks if the file is an ELF binary or a script by its first bytes, or a shell script by its extension.
+func isExecutable(file *os.File) (bool, error) {
+	if filepath.Ext(file.Name()) == ".sh" {
+		return true, nil
+	}
+
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	magic = magic[:n]
+
+	// Rewind the file to archive its content
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	return bytes.HasPrefix(magic, []byte("\x7fELF")) || bytes.HasPrefix(magic, []byte("#!")), nil
+}
+
+// entryMode returns the Unix mode of the archive entry. The executable files get the execute bits if required, the
+// reproducible archives normalize the permissions to 0755 for the executables and 0644 for the other files.
+func entryMode(info os.FileInfo, executable bool, options ZipOptions) os.FileMode {
+	// The symlinks are stored as links, their permissions are not used
+	if info.Mode()&os.ModeSymlink != 0 {
+		return os.ModeSymlink | 0777
+	}
+
+	mode := info.Mode().Perm()
+	if executable {
+		mode |= 0111
+	}
+
+	if options.Reproducible {
+		if mode&0111 != 0 {
+			return 0755
+		}
+		return 0644
+	}
+
+	return mode
+}
+
+// createHeader creates the file header storing the Unix mode in the external attributes, the reproducible entries have
+// the fixed timestamp.
+func createHeader(info os.FileInfo, mode os.FileMode, options ZipOptions) (*zip.FileHeader, error) {
+	var header *zip.FileHeader
+	if options.Reproducible {
+		header = &zip.FileHeader{Modified: options.ModTime}
+	} else {
+		var err error
+		if header, err = zip.FileInfoHeader(info); err != nil {
+			return nil, err
+		}
+	}
+
+	header.Method = zip.Deflate
+	header.SetMode(mode)
+
+	return header, nil
+}
+
 // addToZip takes a zip.Writer, a basePath, and a path of a file.
 // It adds the file to the zip archive using the zip.Writer, preserving the
-// relative path of the file with respect to basePath.
+// relative path of the file with respect to basePath. A symlink is stored as a link with its target as the content.
 // zipWriter: *zip.Writer - The zip writer used to add files to the archive.
 // basePath: string - The base path to calculate the relative path of the file.
 // path: string - The path of the file to be added to the zip archive.
-func addToZip(zipWriter *zip.Writer, basePath, path string) error {
-	file, err := os.Open(path)
+// options: ZipOptions - The reproducible archive and executable options.
+func addToZip(zipWriter *zip.Writer, basePath, path string, options ZipOptions) error {
+	relPath, err := filepath.Rel(basePath, path)
 	if err != nil {
 		return err
 	}
-	defer func(file *os.File) {
-		err := file.Close()
+
+	// Do not follow the symlinks
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
 		if err != nil {
-			logger.Logger.Errorf("failed to close file: %v", err)
+			return err
 		}
-	}(file)
 
-	info, err := file.Stat()
-	if err != nil {
+		header, err := createHeader(info, entryMode(info, false, options), options)
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(relPath)
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(writer, filepath.ToSlash(target))
 		return err
 	}
 
-	header, err := zip.FileInfoHeader(info)
+	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close file: %v", err)
+		}
+	}(file)
+
+	executable := false
+	if options.Executables {
+		if executable, err = isExecutable(file); err != nil {
+			return err
+		}
+	}
 
-	relPath, err := filepath.Rel(basePath, path)
+	header, err := createHeader(info, entryMode(info, executable, options), options)
 	if err != nil {
 		return err
 	}
@@ -52,13 +172,31 @@ func addToZip(zipWriter *zip.Writer, basePath, path string) error {
 	return err
 }
 
-// CreateZipArchive takes an output path, a basePath, and a list of files.
-// It creates a new zip archive at the specified output path and adds the files
-// from the files list to the archive.
-// output: string - The output path for the zip archive.
-// basePath: string - The path to the directory containing the files.
-// files: []string - The list of file paths relative to the basePath to be added to the archive.
-func CreateZipArchive(output, basePath string, files []string) error {
+// hashFile returns the size and the SHA-256 of the file.
+func hashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close file: %v", err)
+		}
+	}(file)
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeZip writes the files to the zip archive, the entries of the reproducible archives are sorted by name and
+// compressed with the fixed level.
+func writeZip(output, basePath string, files []string, options ZipOptions) error {
 	zipFile, err := os.Create(output)
 	if err != nil {
 		return err
@@ -71,20 +209,68 @@ func CreateZipArchive(output, basePath string, files []string) error {
 	}(zipFile)
 
 	zipWriter := zip.NewWriter(zipFile)
-	defer func(zipWriter *zip.Writer) {
-		err := zipWriter.Close()
-		if err != nil {
-			logger.Logger.Errorf("failed to close zip writer: %v", err)
+
+	if options.Reproducible {
+		// The file listing order depends on the file system, sort the entries by their archive path
+		files = append([]string(nil), files...)
+		sort.Slice(files, func(i, j int) bool {
+			return filepath.ToSlash(files[i]) < filepath.ToSlash(files[j])
+		})
+
+		level := options.CompressionLevel
+		if _, err = flate.NewWriter(io.Discard, level); err != nil {
+			return fmt.Errorf("invalid compression level %d: %w", level, err)
 		}
-	}(zipWriter)
+		zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		})
+	}
 
 	for _, file := range files {
 		filePath := filepath.Join(basePath, file)
-		err = addToZip(zipWriter, basePath, filePath)
+		err = addToZip(zipWriter, basePath, filePath, options)
 		if err != nil {
 			return fmt.Errorf("failed to add file %s to zip: %v", filePath, err)
 		}
 	}
 
+	// Close the writer explicitly, it writes the central directory
+	if err = zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+
 	return nil
 }
+
+// CreateZipArchive takes an output path, a basePath, a list of files and the options.
+// It creates a new zip archive at the specified output path and adds the files
+// from the files list to the archive. The reproducible archives are byte-identical for the identical inputs.
+// output: string - The output path for the zip archive.
+// basePath: string - The path to the directory containing the files.
+// files: []string - The list of file paths relative to the basePath to be added to the archive.
+// options: ZipOptions - The reproducible archive options.
+func CreateZipArchive(output, basePath string, files []string, options ZipOptions) (*ZipInfo, error) {
+	if options.Reproducible {
+		if options.ModTime.Before(zipEpoch) {
+			options.ModTime = zipEpoch
+		}
+		// The MS-DOS timestamp depends on the time zone, use UTC on all nodes
+		options.ModTime = options.ModTime.UTC().Truncate(2 * time.Second)
+	}
+
+	if err := writeZip(output, basePath, files, options); err != nil {
+		return nil, err
+	}
+
+	size, sha, err := hashFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash zip file %s: %w", output, err)
+	}
+
+	info := &ZipInfo{Name: filepath.Base(output), Files: len(files), Size: size, Sha256: sha, Reproducible: options.Reproducible}
+	if options.Reproducible {
+		info.ModTime = &options.ModTime
+	}
+
+	return info, nil
+}
@@ -1,62 +1,265 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/secrets"
 	"os"
 	"os/exec"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// expand replaces placeholders in the string s with their corresponding
-// values from the match map.
-func expand(match map[string]string, s string) string {
-	var oldNew []string
-
-	for key, value := range match {
-		oldNew = append(oldNew, "{"+key+"}", value)
+// prepareArguments parses the command line template and expands its placeholders with the values and lists.
+func prepareArguments(commandLine string, values map[string]string, lists map[string][]string) ([]string, error) {
+	t, err := ParseTemplate(commandLine)
+	if err != nil {
+		return nil, err
 	}
 
-	replacer := strings.NewReplacer(oldNew...)
-	return replacer.Replace(s)
+	return t.Expand(values, lists)
 }
 
-// prepareArguments replaces placeholders in the arguments with their corresponding values.
-func prepareArguments(commandLine string, keyValueMap map[string]string) []string {
-	args := strings.Fields(commandLine)
-	for i, arg := range args {
-		args[i] = expand(keyValueMap, arg)
+// executeCommand runs the command with the prepared arguments, environment and limits. The output is streamed line by
+// line to the output handler and the watchdog, the command is terminated with its child processes if the context is
+// cancelled, the timeout is exceeded or the command produces no output for longer than the inactivity timeout.
+func executeCommand(ctx context.Context, c *Cmd) ([]byte, error) {
+	// Apply the resource limits before the command is executed, so its child processes cannot escape them
+	command, arguments, release, err := wrapLimits(c)
+	if err != nil {
+		logger.Logger.Warningf("failed to apply limits to command %s: %v", commandString(c.Command, c.arguments), err)
 	}
-	return args
-}
 
-// executeCommand runs the given command with the specified arguments and working directory.
-func executeCommand(ctx context.Context, command string, arguments []string, workingDir string) ([]byte, error) {
 	cmd := exec.Command(command, arguments...)
-	cmd.Dir = workingDir
-	output, err := cmd.Output()
+	cmd.Dir = c.WorkingDir
+	cmd.Env = buildEnv(c.Env, c.ClearEnv)
+	configureProcess(cmd)
+
+	// Use the pipes directly, so waiting for the command does not wait for the child processes inheriting the pipes
+	if release != nil {
+		defer release()
+	}
 
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
 	if err != nil {
-		err = fmt.Errorf("error executing command: %w\n", err)
-		fmt.Println(err.Error())
+		closePipes(stdoutReader, stdoutWriter)
+		return nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	err = cmd.Start()
+	closePipes(stdoutWriter, stderrWriter)
+	if err != nil {
+		closePipes(stdoutReader, stderrReader)
+		return nil, fmt.Errorf("error starting command: %w", err)
+	}
+
+	// Read the output
+	var stdout, stderr bytes.Buffer
+	watcher := newOutputWatcher(c.OnOutput)
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go watcher.read(stdoutReader, &stdout, &readers)
+	go watcher.read(stderrReader, &stderr, &readers)
+
+	// Supervise the command until it exits
+	done := make(chan struct{})
+	supervisor := &supervisor{cmd: c, pid: cmd.Process.Pid, watcher: watcher}
+	var supervised sync.WaitGroup
+	supervised.Add(1)
+	go func() {
+		defer supervised.Done()
+		supervisor.run(ctx, done)
+	}()
+
+	err = cmd.Wait()
+	close(done)
+	supervised.Wait()
+
+	// Wait for the rest of the output, child processes may keep the pipes open after the command exits
+	if !waitTimeout(&readers, 5*time.Second) {
+		// Closing the pipes unblocks the readers on Unix, but not a pending read on Windows, where the readers stay blocked
+		// until the child processes inheriting the pipes exit (e.g. MSBuild nodes, ShaderCompileWorker). Abandon them then
+		// and return the output read so far.
+		closePipes(stdoutReader, stderrReader)
+		if !waitTimeout(&readers, time.Second) {
+			logger.Logger.Warningf("abandoned output of command %s, child processes keep the output pipes open", commandString(c.Command, c.arguments))
+		}
+	}
+	closePipes(stdoutReader, stderrReader)
+	output := watcher.stop(&stdout, &stderr)
+	stdoutBytes, stderrBytes := output[0], output[1]
+
+	if supervisor.hung != nil {
+		supervisor.hung.Err = err
+		return stdoutBytes, supervisor.hung
 	}
 
-	return output, err
+	if err == nil && ctx.Err() != nil {
+		err = errors.New("terminated")
+	}
+
+	if err != nil {
+		// Include the command stderr into the error, secret values are redacted as the error ends up in logs and job status
+		redactedStderr := secrets.RedactBytes(stderrBytes)
+		logger.Logger.Errorf("error executing command %s: %v\n%s", commandString(c.Command, c.arguments), err, redactedStderr)
+
+		if supervisor.timedOut {
+			err = fmt.Errorf("command timed out after %s: %w", c.Timeout, err)
+		} else if ctx.Err() != nil {
+			err = fmt.Errorf("command cancelled: %v: %w", err, ctx.Err())
+		}
+
+		if tail := lastLines(string(redactedStderr), 10); tail != "" {
+			err = fmt.Errorf("error executing command: %w: %s", err, tail)
+		} else {
+			err = fmt.Errorf("error executing command: %w", err)
+		}
+	}
+
+	return stdoutBytes, err
+}
+
+// closePipes closes the pipe ends ignoring errors, the pipes may be already closed.
+func closePipes(files ...*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// waitTimeout waits for the wait group for up to the timeout, returns false if the timeout is exceeded.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// buildEnv returns the environment for the command: the current process environment, unless clear is set, with the
+// variables from env added or overridden. Returns nil if the environment is inherited without changes.
+func buildEnv(env map[string]string, clear bool) []string {
+	if len(env) == 0 && !clear {
+		return nil
+	}
+
+	// Variable names are case-insensitive on Windows
+	sameKey := func(a, b string) bool {
+		if runtime.GOOS == "windows" {
+			return strings.EqualFold(a, b)
+		}
+		return a == b
+	}
+
+	var result []string
+	if !clear {
+		for _, kv := range os.Environ() {
+			key, _, _ := strings.Cut(kv, "=")
+			overridden := false
+			for k := range env {
+				if sameKey(key, k) {
+					overridden = true
+					break
+				}
+			}
+			if !overridden {
+				result = append(result, kv)
+			}
+		}
+	}
+
+	// Add the variables in a stable order
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		result = append(result, k+"="+env[k])
+	}
+
+	return result
+}
+
+// lastLines returns up to n last non-empty lines of the string joined with "; ".
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
+
+// commandString returns the command line with secret values redacted, used for logging.
+func commandString(command string, arguments []string) string {
+	return secrets.Redact(strings.Join(append([]string{command}, arguments...), " "))
 }
 
 type Cmd struct {
 	// The Command to run, e.g. "git".
 	Command string
 
-	// CommandLine arguments to pass to the command, e.g. "git checkout -b {branchName}".
+	// CommandLine arguments to pass to the command, e.g. "git checkout -b {branchName}". See Template for the quoting
+	// and placeholder syntax, e.g. "-map={maps?}" is omitted when the maps value is empty and "{args...}" is expanded to
+	// an argument per list element.
 	CommandLine string
 
 	// The working directory to run the command in, e.g. "C:\myRepo".
 	WorkingDir string
 
+	// Environment variables to set for the command, e.g. {"DOTNET_CLI_TELEMETRY_OPTOUT": "1"}, override the variables
+	// inherited from the current process.
+	Env map[string]string
+
+	// Do not inherit the environment of the current process, only the Env variables are set.
+	ClearEnv bool
+
+	// Maximum wall-clock time of the command, the command is killed when exceeded, zero means no limit.
+	Timeout time.Duration
+
+	// Nice level of the command, positive values lower the priority, zero keeps the priority of the current process.
+	Nice int
+
+	// Maximum memory of the command in bytes, enforced with a cgroup under the configured cgroup root (Linux only), zero
+	// means no limit.
+	MemoryLimit uint64
+
+	// Maximum time without output lines, the command is considered hung when exceeded, diagnostics are captured and the
+	// command is terminated with a HungError, zero disables the watchdog.
+	InactivityTimeout time.Duration
+
+	// Called for every output line of the command (stdout and stderr), e.g. to parse or log the output while running.
+	OnOutput func(line string)
+
 	// The key-value pairs to use for placeholder replacement, e.g. {"branchName": "myBranch"}.
 	Placeholders map[string]string
 
+	// The lists to use for list placeholder replacement, e.g. {"args": ["-a", "-b"]}.
+	Lists map[string][]string
+
 	// The output of the command, e.g. "Switched to a new branch 'myBranch'".
 	Output []byte
 
@@ -70,9 +273,28 @@ type Cmd struct {
 	arguments []string
 }
 
+// String returns the command line with placeholders expanded and secret values redacted.
+func (c *Cmd) String() string {
+	arguments, err := prepareArguments(c.CommandLine, c.Placeholders, c.Lists)
+	if err != nil {
+		return secrets.Redact(c.Command + " " + c.CommandLine)
+	}
+	return commandString(c.Command, arguments)
+}
+
+// RedactedOutput returns the command output with secret values redacted, use it to log or report the output.
+func (c *Cmd) RedactedOutput() []byte {
+	return secrets.RedactBytes(c.Output)
+}
+
 // Run runs the command.
 func (c *Cmd) Run(ctx context.Context) error {
-	c.arguments = prepareArguments(c.CommandLine, c.Placeholders)
+	var err error
+	c.arguments, err = prepareArguments(c.CommandLine, c.Placeholders, c.Lists)
+	if err != nil {
+		c.Error = fmt.Errorf("invalid command line: %w", err)
+		return c.Error
+	}
 
 	if _, err := os.Stat(c.WorkingDir); os.IsNotExist(err) {
 		c.Error = fmt.Errorf("working directory does not exist: %s", c.WorkingDir)
@@ -84,10 +306,13 @@ func (c *Cmd) Run(ctx context.Context) error {
 		return c.Error
 	}
 
-	c.Output, c.Error = executeCommand(ctx, c.Command, c.arguments, c.WorkingDir)
+	logger.Logger.Debugf("running command: %s", commandString(c.Command, c.arguments))
+
+	c.Output, c.Error = executeCommand(ctx, c)
 
 	if c.Error != nil {
-		if exitError, ok := c.Error.(*exec.ExitError); ok {
+		var exitError *exec.ExitError
+		if errors.As(c.Error, &exitError) {
 			c.ExitCode = exitError.ExitCode()
 		}
 	}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// cgroupCounter makes the cgroup names unique within the process
+var cgroupCounter atomic.Uint64
+
+// cgroupJoinScript moves the shell into the cgroup (the first argument) and replaces the shell with the command, so the
+// command and all its child processes run inside the cgroup from the start
+const cgroupJoinScript = `echo $$ > "$1" && shift && exec "$@"`
+
+// wrapLimits returns the command and the arguments applying the nice level and the memory limit before the command is
+// executed, so the child processes started early and all the threads inherit them. The nice level is applied with the
+// nice tool, the memory limit with a cgroup v2 created under the configured cgroup root, the command joins it before
+// exec. The memory is limited with cgroups only, an address space limit breaks the processes reserving large address
+// ranges, e.g. .NET and Unreal Engine. Returns a function releasing the cgroup, to be called after the process exits,
+// and an error listing the limits that could not be applied, the command is still wrapped with the other limits.
+func wrapLimits(c *Cmd) (string, []string, func(), error) {
+	command, arguments := c.Command, c.arguments
+
+	// The limits are independent, a limit failing to apply is reported and the other one is still applied
+	var problems []string
+
+	if c.Nice != 0 {
+		if nicePath, err := exec.LookPath("nice"); err != nil {
+			problems = append(problems, fmt.Sprintf("failed to set nice level %d: %v", c.Nice, err))
+		} else {
+			arguments = append([]string{"-n", strconv.Itoa(c.Nice), command}, arguments...)
+			command = nicePath
+		}
+	}
+
+	var release func()
+	if c.MemoryLimit != 0 {
+		if config.Config.CgroupRoot == "" {
+			problems = append(problems, fmt.Sprintf("memory limit %d requires a cgroup root (CGROUP_ROOT)", c.MemoryLimit))
+		} else if procsPath, r, err := createCgroup(c.MemoryLimit); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			arguments = append([]string{"-c", cgroupJoinScript, "sh", procsPath, command}, arguments...)
+			command = "/bin/sh"
+			release = r
+		}
+	}
+
+	if len(problems) > 0 {
+		return command, arguments, release, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return command, arguments, release, nil
+}
+
+// createCgroup creates a cgroup with the memory limit, returns the path of its cgroup.procs file.
+func createCgroup(limit uint64) (string, func(), error) {
+	dir := filepath.Join(config.Config.CgroupRoot, fmt.Sprintf("l7-cloud-builder-%d-%d", os.Getpid(), cgroupCounter.Add(1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+
+	// Remove the cgroup after the process exits, the cgroup can be removed only when it has no processes
+	release := func() {
+		if err := os.Remove(dir); err != nil {
+			logger.Logger.Warningf("failed to remove cgroup %s: %v", dir, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatUint(limit, 10)), 0644); err != nil {
+		release()
+		return "", nil, fmt.Errorf("failed to set cgroup memory limit: %w", err)
+	}
+
+	// Disable swap so the limit is not bypassed by swapping, not all the kernels support it
+	_ = os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0644)
+
+	return filepath.Join(dir, "cgroup.procs"), release, nil
+}
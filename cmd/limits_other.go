@@ -0,0 +1,17 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// wrapLimits reports the limits not supported on the current platform, the command runs without them.
+func wrapLimits(c *Cmd) (string, []string, func(), error) {
+	if c.Nice != 0 || c.MemoryLimit != 0 {
+		return c.Command, c.arguments, nil, fmt.Errorf("nice level and memory limit are not supported on %s", runtime.GOOS)
+	}
+
+	return c.Command, c.arguments, nil, nil
+}
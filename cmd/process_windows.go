@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// configureProcess does nothing on Windows, the child processes are found by taskkill.
+func configureProcess(cmd *exec.Cmd) {
+}
+
+// terminateProcessTree kills the process with its child processes.
+func terminateProcessTree(pid int) error {
+	output, err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return nil
+}
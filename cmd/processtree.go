@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// processInfo is a process in the process list
+type processInfo struct {
+	pid     int
+	ppid    int
+	command string
+}
+
+// processTree returns the process tree of the process as text, one process per line indented by the depth.
+func processTree(pid int) (string, error) {
+	processes, err := listProcesses()
+	if err != nil {
+		return "", err
+	}
+
+	return formatProcessTree(pid, processes), nil
+}
+
+// formatProcessTree formats the process with its descendants from the process list.
+func formatProcessTree(root int, processes []processInfo) string {
+	children := map[int][]processInfo{}
+	commands := map[int]string{}
+	for _, p := range processes {
+		children[p.ppid] = append(children[p.ppid], p)
+		commands[p.pid] = p.command
+	}
+
+	var b strings.Builder
+	var visit func(pid int, depth int)
+	visit = func(pid int, depth int) {
+		fmt.Fprintf(&b, "%s%d %s\n", strings.Repeat("  ", depth), pid, commands[pid])
+		c := children[pid]
+		sort.Slice(c, func(i, j int) bool { return c[i].pid < c[j].pid })
+		for _, child := range c {
+			if child.pid != pid {
+				visit(child.pid, depth+1)
+			}
+		}
+	}
+	visit(root, 0)
+
+	return b.String()
+}
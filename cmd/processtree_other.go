@@ -0,0 +1,33 @@
+//go:build !linux && !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// listProcesses lists the running processes using ps.
+func listProcesses() ([]processInfo, error) {
+	output, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,command=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []processInfo
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		processes = append(processes, processInfo{pid: pid, ppid: ppid, command: strings.Join(fields[2:], " ")})
+	}
+
+	return processes, nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// listProcesses lists the running processes using PowerShell and CIM.
+func listProcesses() ([]processInfo, error) {
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		`Get-CimInstance Win32_Process | ForEach-Object { "$($_.ProcessId) $($_.ParentProcessId) $($_.CommandLine)" }`).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProcessList(string(output)), nil
+}
+
+// parseProcessList parses the "pid ppid command" lines.
+func parseProcessList(output string) []processInfo {
+	var processes []processInfo
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		command := ""
+		if len(fields) == 3 {
+			command = fields[2]
+		}
+		processes = append(processes, processInfo{pid: pid, ppid: ppid, command: command})
+	}
+	return processes
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// placeholderKind is a kind of placeholder in a command line template
+type placeholderKind int
+
+const (
+	// placeholderValue is a required placeholder, e.g. "{branch}", replaced with the value
+	placeholderValue placeholderKind = iota
+	// placeholderOptional is an optional placeholder, e.g. "{maps?}", the whole argument is omitted if the value is empty
+	placeholderOptional
+	// placeholderList is a list placeholder, e.g. "{args...}", the argument is repeated for every list element and omitted if the list is empty
+	placeholderList
+)
+
+// segment is a part of a command line template argument, either a literal text or a placeholder
+type segment struct {
+	literal     string
+	placeholder string
+	kind        placeholderKind
+}
+
+// Template is a parsed command line template. The template is split into arguments using shell-style quoting:
+// arguments are separated by whitespace, single quotes preserve the text literally (placeholders are not expanded),
+// double quotes preserve whitespace, a backslash escapes a quote, a backslash, whitespace or a brace and is kept
+// literally otherwise, so Windows paths do not need escaping. Inside double quotes a backslash before the quote ending
+// the argument is kept, so "C:\Builds\" is the directory with the trailing backslash. Placeholders are expanded after
+// the template is split, so placeholder values are always passed as a part of a single argument and are never split or
+// interpreted.
+type Template struct {
+	args [][]segment
+}
+
+// ParseTemplate parses the command line template.
+func ParseTemplate(commandLine string) (*Template, error) {
+	t := &Template{}
+
+	var (
+		arg     []segment
+		literal strings.Builder
+		inArg   bool
+		quote   rune
+	)
+
+	// flushLiteral adds the accumulated literal text to the current argument
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			arg = append(arg, segment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	// flushArg adds the current argument to the template
+	flushArg := func() {
+		flushLiteral()
+		if inArg {
+			t.args = append(t.args, arg)
+		}
+		arg = nil
+		inArg = false
+	}
+
+	runes := []rune(commandLine)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote == '\'':
+			// Single quoted text is kept literally
+			if r == '\'' {
+				quote = 0
+			} else {
+				literal.WriteRune(r)
+			}
+
+		case r == '\\' && quote == '"' && i+1 < len(runes) && runes[i+1] == '"' && (i+2 == len(runes) || isSpace(runes[i+2])):
+			// The quote ending the argument closes the string, the backslash is kept, e.g. "C:\Builds\"
+			inArg = true
+			literal.WriteRune(r)
+
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(`"'\{} `+"\t", runes[i+1]) && (quote == 0 || runes[i+1] == '"' || runes[i+1] == '\\'):
+			// Escaped character
+			inArg = true
+			i++
+			literal.WriteRune(runes[i])
+
+		case r == '"':
+			inArg = true
+			if quote == '"' {
+				quote = 0
+			} else {
+				quote = '"'
+			}
+
+		case r == '\'' && quote == 0:
+			inArg = true
+			quote = '\''
+
+		case isSpace(r) && quote == 0:
+			flushArg()
+
+		case r == '{':
+			inArg = true
+			if name, kind, n, ok := parsePlaceholder(runes[i:]); ok {
+				flushLiteral()
+				arg = append(arg, segment{placeholder: name, kind: kind})
+				i += n - 1
+			} else {
+				literal.WriteRune(r)
+			}
+
+		default:
+			inArg = true
+			literal.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command line: %s", quote, commandLine)
+	}
+
+	flushArg()
+
+	// Validate list placeholders, only one list placeholder per argument is supported
+	for _, arg := range t.args {
+		lists := 0
+		for _, s := range arg {
+			if s.placeholder != "" && s.kind == placeholderList {
+				lists++
+			}
+		}
+		if lists > 1 {
+			return nil, fmt.Errorf("more than one list placeholder in an argument of command line: %s", commandLine)
+		}
+	}
+
+	return t, nil
+}
+
+// parsePlaceholder parses a placeholder at the beginning of the runes, e.g. "{name}", "{name?}" or "{name...}", and
+// returns its name, kind and length. Braces not forming a valid placeholder are treated as literal text.
+func parsePlaceholder(runes []rune) (string, placeholderKind, int, bool) {
+	end := -1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == '}' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", 0, 0, false
+	}
+
+	name := string(runes[1:end])
+	kind := placeholderValue
+	if strings.HasSuffix(name, "...") {
+		name = strings.TrimSuffix(name, "...")
+		kind = placeholderList
+	} else if strings.HasSuffix(name, "?") {
+		name = strings.TrimSuffix(name, "?")
+		kind = placeholderOptional
+	}
+
+	if !isIdentifier(name) {
+		return "", 0, 0, false
+	}
+
+	return name, kind, end + 1, true
+}
+
+// isSpace checks if the rune separates the arguments.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// isIdentifier checks if the string is a valid placeholder name.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Expand expands the placeholders with the values and lists and returns the arguments. It fails if a required
+// placeholder or a list has no value, or if a value or a list is not used by the template.
+func (t *Template) Expand(values map[string]string, lists map[string][]string) ([]string, error) {
+	used := map[string]bool{}
+	var missing []string
+
+	args := make([]string, 0, len(t.args))
+	for _, arg := range t.args {
+		// Find the list placeholder of the argument if any
+		var list []string
+		hasList := false
+		omit := false
+		for _, s := range arg {
+			if s.placeholder == "" {
+				continue
+			}
+			used[s.placeholder] = true
+
+			switch s.kind {
+			case placeholderValue:
+				if _, ok := values[s.placeholder]; !ok {
+					missing = append(missing, s.placeholder)
+				}
+			case placeholderOptional:
+				if values[s.placeholder] == "" {
+					omit = true
+				}
+			case placeholderList:
+				l, ok := lists[s.placeholder]
+				if !ok {
+					missing = append(missing, s.placeholder+"...")
+				}
+				list, hasList = l, true
+			}
+		}
+
+		if omit || (hasList && len(list) == 0) {
+			continue
+		}
+
+		// Render the argument once or once per list element
+		count := 1
+		if hasList {
+			count = len(list)
+		}
+		for i := 0; i < count; i++ {
+			var b strings.Builder
+			for _, s := range arg {
+				switch {
+				case s.placeholder == "":
+					b.WriteString(s.literal)
+				case s.kind == placeholderList:
+					b.WriteString(list[i])
+				default:
+					b.WriteString(values[s.placeholder])
+				}
+			}
+			args = append(args, b.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing placeholder values: %s", strings.Join(unique(missing), ", "))
+	}
+
+	// Check for values not used by the template
+	var unused []string
+	for k := range values {
+		if !used[k] {
+			unused = append(unused, k)
+		}
+	}
+	for k := range lists {
+		if !used[k] {
+			unused = append(unused, k+"...")
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, fmt.Errorf("unused placeholder values: %s", strings.Join(unused, ", "))
+	}
+
+	return args, nil
+}
+
+// unique returns the sorted unique strings.
+func unique(s []string) []string {
+	sort.Strings(s)
+	result := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		commandLine string
+		want        []string // Expected arguments without placeholders
+		wantErr     string   // Expected error, empty if valid
+	}{
+		{name: "whitespace", commandLine: "  fetch\t--tags \n --prune ", want: []string{"fetch", "--tags", "--prune"}},
+		{name: "empty", commandLine: "", want: []string{}},
+		{name: "double quotes", commandLine: `commit -m "two words"`, want: []string{"commit", "-m", "two words"}},
+		{name: "single quotes", commandLine: `log '--format=%H %s'`, want: []string{"log", "--format=%H %s"}},
+		{name: "empty quotes", commandLine: `a "" ''`, want: []string{"a", "", ""}},
+		{name: "adjacent quotes", commandLine: `-map="a b"'+c'`, want: []string{"-map=a b+c"}},
+		{name: "escaped double quote", commandLine: `-ExecCmds="Automation RunTests \"Project.Smoke\""`, want: []string{`-ExecCmds=Automation RunTests "Project.Smoke"`}},
+		{name: "escaped quote outside quotes", commandLine: `say \"hi\"`, want: []string{"say", `"hi"`}},
+		{name: "escaped space", commandLine: `C:\Program\ Files\Epic`, want: []string{`C:\Program Files\Epic`}},
+		{name: "escaped backslash", commandLine: `"C:\Builds\\"`, want: []string{`C:\Builds\`}},
+		{name: "windows path", commandLine: `C:\Builds\Staged\1.0`, want: []string{`C:\Builds\Staged\1.0`}},
+		{name: "quoted windows path", commandLine: `"C:\Program Files\Epic\UE_5.1"`, want: []string{`C:\Program Files\Epic\UE_5.1`}},
+		{name: "trailing backslash", commandLine: `-archivedirectory="C:\Builds\" -stage`, want: []string{`-archivedirectory=C:\Builds\`, "-stage"}},
+		{name: "trailing backslash at end", commandLine: `-stagingdirectory="C:\Staged\"`, want: []string{`-stagingdirectory=C:\Staged\`}},
+		{name: "single quotes keep backslashes", commandLine: `'C:\Builds\'`, want: []string{`C:\Builds\`}},
+		{name: "escaped brace", commandLine: `\{literal}`, want: []string{"{literal}"}},
+		{name: "invalid placeholder is literal", commandLine: `{not valid} {1x}`, want: []string{"{not", "valid}", "{1x}"}},
+		{name: "unterminated double quote", commandLine: `commit -m "open`, wantErr: `unterminated " quote`},
+		{name: "unterminated single quote", commandLine: `log 'open`, wantErr: `unterminated ' quote`},
+		{name: "two lists in an argument", commandLine: `{a...}{b...}`, wantErr: "more than one list placeholder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := ParseTemplate(tt.commandLine)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTemplate() error = %v", err)
+			}
+
+			got, err := template.Expand(nil, nil)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateExpand(t *testing.T) {
+	tests := []struct {
+		name        string
+		commandLine string
+		values      map[string]string
+		lists       map[string][]string
+		want        []string
+		wantErr     string // Expected error, empty if valid
+	}{
+		{
+			name:        "value",
+			commandLine: "checkout {branch}",
+			values:      map[string]string{"branch": "main"},
+			want:        []string{"checkout", "main"},
+		},
+		{
+			name:        "value with whitespace and quotes is one argument",
+			commandLine: "commit -m {message}",
+			values:      map[string]string{"message": `fix "the" bug; rm -rf /`},
+			want:        []string{"commit", "-m", `fix "the" bug; rm -rf /`},
+		},
+		{
+			name:        "value inside an argument",
+			commandLine: `-project="{dir}\{name}.uproject"`,
+			values:      map[string]string{"dir": `C:\Projects`, "name": "Metaverse"},
+			want:        []string{`-project=C:\Projects\Metaverse.uproject`},
+		},
+		{
+			name:        "empty required value is kept",
+			commandLine: "-m {message}",
+			values:      map[string]string{"message": ""},
+			want:        []string{"-m", ""},
+		},
+		{
+			name:        "optional value",
+			commandLine: "describe --match={pattern?} HEAD",
+			values:      map[string]string{"pattern": "v*"},
+			want:        []string{"describe", "--match=v*", "HEAD"},
+		},
+		{
+			name:        "empty optional value omits the argument",
+			commandLine: "describe --match={pattern?} HEAD",
+			values:      map[string]string{"pattern": ""},
+			want:        []string{"describe", "HEAD"},
+		},
+		{
+			name:        "missing optional value omits the argument",
+			commandLine: "describe --match={pattern?} HEAD",
+			want:        []string{"describe", "HEAD"},
+		},
+		{
+			name:        "list",
+			commandLine: "BuildCookRun {args...} -utf8output",
+			lists:       map[string][]string{"args": {"-build", "-cook", "-map=A B"}},
+			want:        []string{"BuildCookRun", "-build", "-cook", "-map=A B", "-utf8output"},
+		},
+		{
+			name:        "list inside an argument is repeated",
+			commandLine: "lfs fetch --include={paths...}",
+			lists:       map[string][]string{"paths": {"Content/**", "Plugins/**"}},
+			want:        []string{"lfs", "fetch", "--include=Content/**", "--include=Plugins/**"},
+		},
+		{
+			name:        "empty list omits the argument",
+			commandLine: "run {args...} --end",
+			lists:       map[string][]string{"args": {}},
+			want:        []string{"run", "--end"},
+		},
+		{
+			name:        "single quoted placeholder is literal",
+			commandLine: "echo '{name}'",
+			want:        []string{"echo", "{name}"},
+		},
+		{
+			name:        "missing value",
+			commandLine: "checkout {branch} {commit}",
+			values:      map[string]string{"commit": "abc"},
+			wantErr:     "missing placeholder values: branch",
+		},
+		{
+			name:        "missing list",
+			commandLine: "run {args...}",
+			wantErr:     "missing placeholder values: args...",
+		},
+		{
+			name:        "missing values are listed once",
+			commandLine: "{b} {a} {b}",
+			wantErr:     "missing placeholder values: a, b",
+		},
+		{
+			name:        "unused value",
+			commandLine: "fetch",
+			values:      map[string]string{"branch": "main"},
+			wantErr:     "unused placeholder values: branch",
+		},
+		{
+			name:        "unused list",
+			commandLine: "fetch {remote}",
+			values:      map[string]string{"remote": "origin"},
+			lists:       map[string][]string{"args": {"--tags"}},
+			wantErr:     "unused placeholder values: args...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := ParseTemplate(tt.commandLine)
+			if err != nil {
+				t.Fatalf("ParseTemplate() error = %v", err)
+			}
+
+			got, err := template.Expand(tt.values, tt.lists)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
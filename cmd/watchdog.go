@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/secrets"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tailSize is the number of the last output lines kept for the hung command diagnostics
+const tailSize = 50
+
+// HungError is returned when the command produces no output for longer than the inactivity timeout and is terminated
+type HungError struct {
+	Command     string        // Command line with secret values redacted
+	Inactivity  time.Duration // Time since the last output line when the command was terminated
+	LastLines   []string      // Last output lines of the command with secret values redacted
+	ProcessTree string        // Process tree of the command captured before the termination
+	Err         error         // Error returned by the terminated command
+}
+
+// Error returns the error message.
+func (e *HungError) Error() string {
+	return fmt.Sprintf("command hung, no output for %s: %s", e.Inactivity.Round(time.Second), e.Command)
+}
+
+// Unwrap returns the error returned by the terminated command.
+func (e *HungError) Unwrap() error {
+	return e.Err
+}
+
+// outputWatcher reads the command output line by line, keeps the last lines and the time of the last line
+type outputWatcher struct {
+	onOutput func(line string)
+	lastLine atomic.Int64 // Unix time of the last output line in nanoseconds
+
+	mu      sync.Mutex
+	tail    []string
+	stopped bool // Set when the output is no longer recorded, the abandoned readers may still be blocked reading the pipes
+}
+
+// newOutputWatcher creates a new output watcher calling the handler for every line.
+func newOutputWatcher(onOutput func(line string)) *outputWatcher {
+	w := &outputWatcher{onOutput: onOutput}
+	w.lastLine.Store(time.Now().UnixNano())
+	return w
+}
+
+// read reads the lines from the reader until EOF, writes them to the buffer and records them.
+func (w *outputWatcher) read(r io.Reader, buffer *bytes.Buffer, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			w.mu.Lock()
+			if w.stopped {
+				w.mu.Unlock()
+				return
+			}
+			buffer.WriteString(line)
+			w.tail = append(w.tail, strings.TrimRight(line, "\r\n"))
+			if len(w.tail) > tailSize {
+				w.tail = w.tail[len(w.tail)-tailSize:]
+			}
+
+			w.lastLine.Store(time.Now().UnixNano())
+
+			// The handler is called under the lock, so it is never called after the output is stopped
+			if w.onOutput != nil {
+				w.onOutput(strings.TrimRight(line, "\r\n"))
+			}
+			w.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stop stops recording the output and returns copies of the buffers, so the abandoned readers cannot modify the output
+// returned to the caller.
+func (w *outputWatcher) stop(buffers ...*bytes.Buffer) [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopped = true
+
+	copies := make([][]byte, len(buffers))
+	for i, b := range buffers {
+		copies[i] = append([]byte(nil), b.Bytes()...)
+	}
+	return copies
+}
+
+// idle returns the time since the last output line.
+func (w *outputWatcher) idle() time.Duration {
+	return time.Since(time.Unix(0, w.lastLine.Load()))
+}
+
+// lastLines returns the last output lines.
+func (w *outputWatcher) lastLines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.tail...)
+}
+
+// supervisor terminates the command when the context is cancelled, the timeout is exceeded or the command hangs
+type supervisor struct {
+	cmd     *Cmd
+	pid     int
+	watcher *outputWatcher
+
+	timedOut bool       // Set if the command was terminated because of the timeout
+	hung     *HungError // Set if the command was terminated because of the inactivity
+}
+
+// run supervises the command until the done channel is closed.
+func (s *supervisor) run(ctx context.Context, done <-chan struct{}) {
+	var timeout <-chan time.Time
+	if s.cmd.Timeout > 0 {
+		timer := time.NewTimer(s.cmd.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var check <-chan time.Time
+	if s.cmd.InactivityTimeout > 0 {
+		interval := s.cmd.InactivityTimeout / 4
+		if interval > 10*time.Second {
+			interval = 10 * time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		check = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			s.terminate()
+			return
+		case <-timeout:
+			s.timedOut = true
+			s.terminate()
+			return
+		case <-check:
+			if idle := s.watcher.idle(); idle >= s.cmd.InactivityTimeout {
+				s.hung = s.diagnose(idle)
+				s.terminate()
+				return
+			}
+		}
+	}
+}
+
+// diagnose captures the process tree and the last output lines of the hung command.
+func (s *supervisor) diagnose(idle time.Duration) *HungError {
+	e := &HungError{
+		Command:    commandString(s.cmd.Command, s.cmd.arguments),
+		Inactivity: idle,
+		LastLines:  secrets.RedactAll(s.watcher.lastLines()),
+	}
+
+	tree, err := processTree(s.pid)
+	if err != nil {
+		tree = fmt.Sprintf("failed to get process tree: %v", err)
+	}
+	e.ProcessTree = secrets.Redact(tree)
+
+	logger.Logger.Errorf("command hung, no output for %s, terminating: %s\nprocess tree:\n%s\nlast output lines:\n%s",
+		idle.Round(time.Second), e.Command, e.ProcessTree, strings.Join(e.LastLines, "\n"))
+
+	return e
+}
+
+// terminate kills the command process with its child processes.
+func (s *supervisor) terminate() {
+	if err := terminateProcessTree(s.pid); err != nil {
+		logger.Logger.Errorf("failed to terminate process %d: %v", s.pid, err)
+	}
+}
@@ -5,6 +5,8 @@
 
 package config
 
+import "time"
+
 // JobType is a type for job
 type JobType int
 
@@ -13,6 +15,10 @@ const (
 	JobTypeRelease JobType = iota
 	// JobTypePackage is a job type for package jobs (build the Unreal Engine UGC package for a specific platform, configuration and target)
 	JobTypePackage
+	// JobTypeTest is a job type for test jobs (run the Unreal Engine project automation tests headless at a specific code version)
+	JobTypeTest
+	// JobTypeValidate is a job type for validate jobs (run the Unreal Engine data validation on the UGC package content before the package job)
+	JobTypeValidate
 )
 
 // TargetType is a type for target
@@ -69,6 +75,26 @@ const (
 	JobStatusCancelled
 )
 
+// FailureCategoryType is a type for job failure category
+type FailureCategoryType int
+
+const (
+	// FailureCategoryUnknown is a failure category for failures not matching any other category
+	FailureCategoryUnknown FailureCategoryType = iota
+	// FailureCategoryInfra is a failure category for build node failures (missing tools, no disk space, timeouts)
+	FailureCategoryInfra
+	// FailureCategoryTransient is a failure category for failures likely to pass on retry (network, DDC locks, shader compiler crashes)
+	FailureCategoryTransient
+	// FailureCategoryCode is a failure category for compile and link errors
+	FailureCategoryCode
+	// FailureCategoryContent is a failure category for cook errors and crashes while processing content
+	FailureCategoryContent
+	// FailureCategoryConfig is a failure category for invalid job metadata, project descriptor or tool configuration
+	FailureCategoryConfig
+	// FailureCategoryCancelled is a failure category for cancelled jobs
+	FailureCategoryCancelled
+)
+
 // ApiConfig is a struct for API configuration
 type ApiConfig struct {
 	Url             string // URL to the API (supplied via environment variable)
@@ -78,20 +104,33 @@ type ApiConfig struct {
 	CredentialsPath string // Path to the credentials file (used to store credentials if they are not provided via environment variables)
 }
 
+// RepoConfig is a struct for a source repository configuration, the repository is cloned if the source directory is missing
+type RepoConfig struct {
+	Url       string // Remote URL, e.g. "https://git.example.com/project.git" or a local bare repository path
+	Branch    string // Branch checked out after cloning, the remote HEAD is used if empty
+	Filter    string // Partial clone filter, e.g. "blob:none"
+	Depth     int    // Shallow clone depth, zero clones the full history
+	Reference string // Reference repository sharing its objects with the clone
+	Worktree  string // Main repository the source directory is added to as a worktree instead of cloning
+}
+
 // ClientLauncherConfig is a struct for client launcher configuration
 type ClientLauncherConfig struct {
-	WailsPath string // Path to Wails CLI
-	SourceDir string // Path to the client launcher source code
+	WailsPath string     // Path to Wails CLI
+	SourceDir string     // Path to the client launcher source code
+	Repo      RepoConfig // Client launcher source repository
 }
 
 // ServerLauncherConfig is a struct for server launcher configuration
 type ServerLauncherConfig struct {
-	SourceDir string // Path to the server launcher source code
+	SourceDir string     // Path to the server launcher source code
+	Repo      RepoConfig // Server launcher source repository
 }
 
 // PixelStreamingLauncherConfig is a struct for Pixel Streaming launcher configuration
 type PixelStreamingLauncherConfig struct {
-	SourceDir string // Path to the Pixel Streaming launcher source code
+	SourceDir string     // Path to the Pixel Streaming launcher source code
+	Repo      RepoConfig // Pixel Streaming launcher source repository
 }
 
 // UnrealEngineVersionConfig is a struct for Unreal Engine version configuration
@@ -102,14 +141,33 @@ type UnrealEngineVersionConfig struct {
 	Version             string // Unreal Engine version, used to select the correct version of the Unreal Engine with UVS, used only for Marketplace version
 }
 
+// EngineConfig is a struct for an installed Unreal Engine configuration
+type EngineConfig struct {
+	Id      string // Engine identifier advertised to the API, e.g. "5.1-source", the version or the directory name is used if empty
+	RootDir string // Path to the engine root directory containing the Engine directory
+}
+
+// CommandLimitsConfig is a struct for command resource limits configuration
+type CommandLimitsConfig struct {
+	Timeout           time.Duration // Maximum wall-clock time of a command, zero means no limit
+	Nice              int           // Nice level of a command, zero keeps the current priority
+	MemoryLimit       uint64        // Maximum memory of a command in bytes, zero means no limit
+	InactivityTimeout time.Duration // Maximum time without output before a command is considered hung and terminated, zero disables the watchdog
+}
+
 // UnrealConfig is a struct for Unreal Engine configuration
 type UnrealConfig struct {
 	Project struct {
-		Directory string // Path to the project
-		Name      string // Project name
+		Directory string     // Path to the project
+		Name      string     // Project name
+		Repo      RepoConfig // Project source repository
 	}
 	Code        UnrealEngineVersionConfig // Code version Unreal Engine version configuration
 	Marketplace UnrealEngineVersionConfig // Marketplace version Unreal Engine version configuration
+	Environment map[string]string         // Environment variables for Unreal Automation Tool and Editor commands, e.g. LINUX_MULTIARCH_ROOT
+	Limits      CommandLimitsConfig       // Resource limits for Unreal Automation Tool and Editor commands
+	Engines     []EngineConfig            // Installed engines, jobs select the engine the project is associated with
+	EngineDirs  []string                  // Directories scanned for installed engines, e.g. "/opt/UnrealEngine"
 }
 
 // CodeSigningConfig is a struct for code signing configuration
@@ -121,25 +179,131 @@ type CodeSigningConfig struct {
 
 // AutomationConfig is a struct for the automation tool configuration
 type AutomationConfig struct {
-	EnabledTargets   map[string]bool          // Targets enabled for this automation tool (Client, Server, SDK)
-	EnabledJobs      map[string]bool          // Job types enabled for this automation tool (ClientLauncher, Release, Package)
-	EnabledPlatforms map[string]bool          // Platforms enabled for this automation tool (Windows, Linux, Mac, Android, iOS)
-	StatusMapping    map[JobStatusType]string // Job statuses mapped to strings
-	JobMapping       map[JobType]string       // Job types mapped to strings
-	TargetMapping    map[TargetType]string    // Target types mapped to strings
-	PlatformMapping  map[PlatformType]string  // Platform types mapped to strings
+	EnabledTargets   map[string]bool                // Targets enabled for this automation tool (Client, Server, SDK)
+	EnabledJobs      map[string]bool                // Job types enabled for this automation tool (ClientLauncher, Release, Package)
+	EnabledPlatforms map[string]bool                // Platforms enabled for this automation tool (Windows, Linux, Mac, Android, iOS)
+	StatusMapping    map[JobStatusType]string       // Job statuses mapped to strings
+	JobMapping       map[JobType]string             // Job types mapped to strings
+	TargetMapping    map[TargetType]string          // Target types mapped to strings
+	PlatformMapping  map[PlatformType]string        // Platform types mapped to strings
+	FailureMapping   map[FailureCategoryType]string // Failure categories mapped to strings
+
+	SharedConfigReloadInterval time.Duration // Interval between the shared configuration reloads, the configuration is reloaded between jobs
+	CgroupRoot                 string        // Path to a delegated cgroup v2 directory used to limit command memory, the memory is not limited if empty (Linux only)
+	SourceDateEpoch            time.Time     // Timestamp of the reproducible release archive entries, the release commit date is used if zero
 }
 
 // GitConfig is a struct for Git configuration
 type GitConfig struct {
-	BranchMapping map[string]string // Mapping of branch names to target names (what branch to select for a target)
+	BranchMapping      map[string]string // Mapping of configurations to branch names (what branch to build for a configuration without a code version)
+	VerifyTags         bool              // Require the release tags to be signed and verify their signatures
+	AllowedSignersFile string            // Allowed signers file used to verify the SSH tag signatures, the GPG signatures are verified with the keyring
+}
+
+// SharedReleaseConfig is a struct for shared release configuration
+type SharedReleaseConfig struct {
+	IgnoredFiles []string            `json:"ignoredFiles"` // List of files to ignore when packing a release into a zip archive
+	Archive      SharedArchiveConfig `json:"archive"`      // Release zip archive configuration
+}
+
+// SharedArchiveConfig is a struct for the release zip archive configuration
+type SharedArchiveConfig struct {
+	Reproducible     bool `json:"reproducible"`     // Create byte-identical archives for the identical inputs: sorted entries, fixed timestamp, normalized permissions
+	CompressionLevel int  `json:"compressionLevel"` // Deflate compression level of the reproducible archives, from 1 (fastest) to 9 (best)
+}
+
+// SharedBuildCookRunArgs is a struct for extra BuildCookRun arguments applied to the jobs matching the target, platform and configuration
+type SharedBuildCookRunArgs struct {
+	Target        string   `json:"target"`        // Job target the arguments are applied to, empty value matches any target
+	Platform      string   `json:"platform"`      // Job platform the arguments are applied to, empty value matches any platform
+	Configuration string   `json:"configuration"` // Job configuration the arguments are applied to, empty value matches any configuration
+	Args          []string `json:"args"`          // Arguments appended to the BuildCookRun command line
+}
+
+// SharedBuildCookRunConfig is a struct for shared BuildCookRun configuration
+type SharedBuildCookRunConfig struct {
+	ExtraArgs []SharedBuildCookRunArgs `json:"extraArgs"` // Extra arguments, all matching entries are applied in order
+}
+
+// SharedIniOverride is a struct for a project config value overridden before the build for the jobs matching the target, platform and configuration
+type SharedIniOverride struct {
+	Target        string `json:"target"`        // Job target the override is applied to, empty value matches any target
+	Platform      string `json:"platform"`      // Job platform the override is applied to, empty value matches any platform
+	Configuration string `json:"configuration"` // Job configuration the override is applied to, empty value matches any configuration
+	File          string `json:"file"`          // Project config file name, e.g. "Game" for Config/DefaultGame.ini
+	Section       string `json:"section"`       // Config section, e.g. "/Script/Metaverse.ApiSettings"
+	Key           string `json:"key"`           // Config key, e.g. "ApiUrl"
+	Value         string `json:"value"`         // Config value, placeholders {apiUrl}, {releaseVersion} and {jobId} are replaced
+}
+
+// SharedSmokeTestConfig is a struct for the dedicated server smoke test configuration, the test runs after the server release is staged
+type SharedSmokeTestConfig struct {
+	Enabled        bool     `json:"enabled"`        // Run the smoke test for the Linux server releases
+	ReadyPattern   string   `json:"readyPattern"`   // Regular expression matching the server log line when the server is ready
+	TimeoutSeconds int      `json:"timeoutSeconds"` // Time for the server to get ready in seconds
+	Args           []string `json:"args"`           // Server arguments passed before the default ones, e.g. the map to load
+}
+
+// SharedTestConfig is a struct for the automation test job configuration
+type SharedTestConfig struct {
+	Filter string `json:"filter"` // Automation test filter, e.g. "Project" or "Project.Functional Tests+Project.Unit"
+}
+
+// SharedValidationConfig is a struct for the content validation job configuration
+type SharedValidationConfig struct {
+	Commandlet string   `json:"commandlet"` // Editor commandlet running the validation, e.g. "DataValidation"
+	Args       []string `json:"args"`       // Commandlet arguments
+}
+
+// SharedWorkspaceConfig is a struct for the project workspace hygiene configuration, the workspace is reset and cleaned before the checkout
+type SharedWorkspaceConfig struct {
+	Keep       []string          `json:"keep"`       // Ignored files and directories kept by the clean (git clean -e patterns), e.g. "DerivedDataCache"
+	Submodules bool              `json:"submodules"` // Update the submodules recursively after the checkout
+	Lfs        bool              `json:"lfs"`        // Fetch and checkout the LFS objects after the checkout
+	LfsFilters []SharedLfsFilter `json:"lfsFilters"` // LFS include and exclude patterns, all matching entries are applied
+}
+
+// SharedLfsFilter is a struct for the LFS paths fetched for the jobs matching the target and platform, e.g. to skip the client-only art for the server builds
+type SharedLfsFilter struct {
+	Target   string   `json:"target"`   // Job target the filter is applied to, empty value matches any target
+	Platform string   `json:"platform"` // Job platform the filter is applied to, empty value matches any platform
+	Include  []string `json:"include"`  // LFS paths to fetch, e.g. "Content/**"
+	Exclude  []string `json:"exclude"`  // LFS paths to skip, e.g. "Content/ClientOnly/**"
+}
+
+// SharedChangelogConfig is a struct for the release changelog configuration, the changelog lists the commits since the previous release tag
+type SharedChangelogConfig struct {
+	Enabled    bool   `json:"enabled"`    // Generate and upload the changelog with the releases
+	TagPattern string `json:"tagPattern"` // Pattern of the release tags used to find the previous release, e.g. "v*", empty value matches any tag
+	IssueUrl   string `json:"issueUrl"`   // Issue link with the {key} placeholder, e.g. "https://jira.example.com/browse/{key}"
+	MaxCommits int    `json:"maxCommits"` // Maximum number of the commits listed, zero means no limit
+}
+
+// SharedUploadConfig is a struct for shared upload configuration
+type SharedUploadConfig struct {
+	ChunkSize int `json:"chunkSize"` // Size of the chunks used to stream files to the API, in bytes
+}
+
+// SharedRetryPolicy is a struct for a retry policy of a job processing phase, only transient failures are retried
+type SharedRetryPolicy struct {
+	Attempts     int `json:"attempts"`     // Maximum number of attempts including the first one
+	DelaySeconds int `json:"delaySeconds"` // Delay between the attempts in seconds
 }
 
 // SharedConfig is a struct for shared configuration for the automation tool, stored in the database and allows to change the configuration without restarting the automation tool
 type SharedConfig struct {
-	Release struct {
-		IgnoredFiles []string // List of files to ignore when packing a release into a zip archive
-	}
+	Version      string                       `json:"version"`      // Version of the configuration, the configuration is applied only if the version changes
+	Release      SharedReleaseConfig          `json:"release"`      // Release configuration
+	BuildCookRun SharedBuildCookRunConfig     `json:"buildCookRun"` // BuildCookRun configuration
+	Maps         map[string][]string          `json:"maps"`         // Named lists of maps, e.g. maps to cook for a target
+	Upload       SharedUploadConfig           `json:"upload"`       // Upload configuration
+	Retry        map[string]SharedRetryPolicy `json:"retry"`        // Retry policies by job processing phase, e.g. "sync", "build", "upload"
+	Ini          []SharedIniOverride          `json:"ini"`          // Project config values overridden before the build, e.g. the API URL of the environment
+	SmokeTest    SharedSmokeTestConfig        `json:"smokeTest"`    // Dedicated server smoke test configuration
+	Test         SharedTestConfig             `json:"test"`         // Automation test job configuration
+	Validation   SharedValidationConfig       `json:"validation"`   // Content validation job configuration
+	Workspace    SharedWorkspaceConfig        `json:"workspace"`    // Project workspace hygiene configuration
+	Changelog    SharedChangelogConfig        `json:"changelog"`    // Release changelog configuration
 }
 
 var (
@@ -154,7 +318,11 @@ var (
 	// PixelStreamingLauncher contains configuration for the launcher
 	PixelStreamingLauncher = PixelStreamingLauncherConfig{}
 	// Unreal contains configuration for Unreal Engine
-	Unreal = UnrealConfig{}
+	Unreal = UnrealConfig{
+		Environment: map[string]string{
+			"DOTNET_CLI_TELEMETRY_OPTOUT": "1",
+		},
+	}
 	// CodeSigning contains configuration for code signing
 	CodeSigning = CodeSigningConfig{}
 	// Config contains configuration for supported targets, jobs, platforms and statuses
@@ -172,8 +340,10 @@ var (
 			JobStatusCancelled:  "cancelled",
 		},
 		JobMapping: map[JobType]string{
-			JobTypeRelease: "release",
-			JobTypePackage: "package",
+			JobTypeRelease:  "release",
+			JobTypePackage:  "package",
+			JobTypeTest:     "test",
+			JobTypeValidate: "validate",
 		},
 		TargetMapping: map[TargetType]string{
 			TargetTypeClient:                 "client",
@@ -183,6 +353,16 @@ var (
 			TargetTypeServerLauncher:         "server-launcher",
 			TargetTypePixelStreamingLauncher: "pixel-streaming-launcher",
 		},
+		FailureMapping: map[FailureCategoryType]string{
+			FailureCategoryUnknown:   "unknown",
+			FailureCategoryInfra:     "infra",
+			FailureCategoryTransient: "transient",
+			FailureCategoryCode:      "code",
+			FailureCategoryContent:   "content",
+			FailureCategoryConfig:    "config",
+			FailureCategoryCancelled: "cancelled",
+		},
+		SharedConfigReloadInterval: 5 * time.Minute,
 	}
 	// Git contains configuration for Git
 	Git = GitConfig{
@@ -194,11 +374,12 @@ var (
 			"Shipping":    "development",
 		},
 	}
-	// Shared contains shared configuration for the automation tool, stored in the database and allows to change the configuration without restarting the automation tool
-	Shared = SharedConfig{
-		Release: struct {
-			IgnoredFiles []string
-		}{
+)
+
+// DefaultSharedConfiguration returns the default shared configuration used until the configuration is loaded from the API, the API configuration is applied on top of it
+func DefaultSharedConfiguration() *SharedConfig {
+	return &SharedConfig{
+		Release: SharedReleaseConfig{
 			IgnoredFiles: []string{
 				".git",
 				".gitignore",
@@ -229,6 +410,35 @@ var (
 				"Engine/Extras/GPUDumpViewer/GPUDumpViewer.html",
 				"Samples/PixelStreaming", // Remove Pixel Streaming samples from the release
 			},
+			Archive: SharedArchiveConfig{
+				CompressionLevel: 6,
+			},
+		},
+		Maps: map[string][]string{},
+		Upload: SharedUploadConfig{
+			ChunkSize: 100 * 1024 * 1024, // 100MiB
+		},
+		Retry: map[string]SharedRetryPolicy{
+			"sync":   {Attempts: 3, DelaySeconds: 30},
+			"build":  {Attempts: 2, DelaySeconds: 60},
+			"upload": {Attempts: 3, DelaySeconds: 30},
+		},
+		SmokeTest: SharedSmokeTestConfig{
+			ReadyPattern:   "Engine is initialized",
+			TimeoutSeconds: 300,
+		},
+		Test: SharedTestConfig{
+			Filter: "Project",
+		},
+		Validation: SharedValidationConfig{
+			Commandlet: "DataValidation",
+		},
+		Workspace: SharedWorkspaceConfig{
+			Keep: []string{"DerivedDataCache", "Intermediate"},
+		},
+		Changelog: SharedChangelogConfig{
+			Enabled:    true,
+			MaxCommits: 500,
 		},
 	}
-)
+}
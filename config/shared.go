@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// shared contains the current shared configuration, replaced atomically as a whole when a new configuration is loaded
+var shared atomic.Pointer[SharedConfig]
+
+func init() {
+	shared.Store(DefaultSharedConfiguration())
+}
+
+// SharedConfiguration returns the current shared configuration. Jobs should get the configuration once and use it for
+// the whole job, so a job never sees a partially updated configuration. The returned configuration must not be modified.
+func SharedConfiguration() *SharedConfig {
+	return shared.Load()
+}
+
+// SetSharedConfiguration atomically replaces the current shared configuration and returns the previous one.
+func SetSharedConfiguration(c *SharedConfig) *SharedConfig {
+	return shared.Swap(c)
+}
+
+// BuildCookRunArgs returns the extra BuildCookRun arguments of all entries matching the target, platform and configuration.
+func (c *SharedConfig) BuildCookRunArgs(target, platform, configuration string) []string {
+	var args []string
+	for _, e := range c.BuildCookRun.ExtraArgs {
+		if (e.Target == "" || e.Target == target) &&
+			(e.Platform == "" || e.Platform == platform) &&
+			(e.Configuration == "" || e.Configuration == configuration) {
+			args = append(args, e.Args...)
+		}
+	}
+	return args
+}
+
+// IniOverrides returns the project config overrides of all entries matching the target, platform and configuration.
+func (c *SharedConfig) IniOverrides(target, platform, configuration string) []SharedIniOverride {
+	var overrides []SharedIniOverride
+	for _, e := range c.Ini {
+		if (e.Target == "" || e.Target == target) &&
+			(e.Platform == "" || e.Platform == platform) &&
+			(e.Configuration == "" || e.Configuration == configuration) {
+			overrides = append(overrides, e)
+		}
+	}
+	return overrides
+}
+
+// LfsFilter returns the LFS include and exclude patterns of all entries matching the target and platform.
+func (c *SharedConfig) LfsFilter(target, platform string) (include []string, exclude []string) {
+	for _, e := range c.Workspace.LfsFilters {
+		if (e.Target == "" || e.Target == target) &&
+			(e.Platform == "" || e.Platform == platform) {
+			include = append(include, e.Include...)
+			exclude = append(exclude, e.Exclude...)
+		}
+	}
+	return include, exclude
+}
+
+// DiffSharedConfiguration returns the list of human-readable changes between the old and new configuration, one line per
+// changed value, e.g. "release.ignoredFiles.3: "*.pdb" -> <removed>".
+func DiffSharedConfiguration(old, new *SharedConfig) ([]string, error) {
+	oldValues, err := flattenSharedConfiguration(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newValues, err := flattenSharedConfiguration(new)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect all the keys
+	keys := make([]string, 0, len(oldValues)+len(newValues))
+	for k := range oldValues {
+		keys = append(keys, k)
+	}
+	for k := range newValues {
+		if _, ok := oldValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var diff []string
+	for _, k := range keys {
+		o, oldOk := oldValues[k]
+		n, newOk := newValues[k]
+		switch {
+		case !oldOk:
+			diff = append(diff, fmt.Sprintf("%s: <added> -> %s", k, n))
+		case !newOk:
+			diff = append(diff, fmt.Sprintf("%s: %s -> <removed>", k, o))
+		case o != n:
+			diff = append(diff, fmt.Sprintf("%s: %s -> %s", k, o, n))
+		}
+	}
+
+	return diff, nil
+}
+
+// flattenSharedConfiguration converts the configuration into a map of dot-separated JSON paths to JSON encoded values.
+func flattenSharedConfiguration(c *SharedConfig) (map[string]string, error) {
+	values := map[string]string{}
+	if c == nil {
+		return values, nil
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	flattenValue("", v, values)
+
+	return values, nil
+}
+
+// flattenValue recursively adds the leaf values of v to the values map.
+func flattenValue(path string, v interface{}, values map[string]string) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			flattenValue(join(k), e, values)
+		}
+	case []interface{}:
+		for i, e := range t {
+			flattenValue(join(fmt.Sprint(i)), e, values)
+		}
+	default:
+		b, _ := json.Marshal(t)
+		values[path] = string(b)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"l7-cloud-builder/secrets"
 	"os"
 	"strconv"
 	"time"
@@ -17,7 +18,10 @@ func SetupClickhouse(ctx context.Context) (context.Context, error) {
 	clickhouseHost := os.Getenv("CLICKHOUSE_HOST")
 	clickhousePort := os.Getenv("CLICKHOUSE_PORT")
 	clickhouseUser := os.Getenv("CLICKHOUSE_USER")
-	clickhousePass := os.Getenv("CLICKHOUSE_PASS")
+	clickhousePass, err := secrets.Getenv("CLICKHOUSE_PASS")
+	if err != nil {
+		return ctx, err
+	}
 	clickhouseName := os.Getenv("CLICKHOUSE_NAME")
 
 	clickhousePortNum, err := strconv.Atoi(clickhousePort)
@@ -0,0 +1,235 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"l7-cloud-builder/cmd"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/unreal"
+	"l7-cloud-builder/unreallog"
+	"net"
+	"strings"
+)
+
+// Error is a failure of a job processing phase with its category
+type Error struct {
+	Phase    string                     // Job processing phase, e.g. "sync", "build" or "upload"
+	Category config.FailureCategoryType // Category of the failure
+	Attempts int                        // Number of attempts made
+	Err      error                      // Error of the last attempt
+}
+
+// Error returns the error message of the last attempt.
+func (e *Error) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (%s failed after %d attempts)", e.Err, e.Phase, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// transientPatterns are the error messages of the failures likely to pass on retry
+	transientPatterns = []string{
+		// Network
+		"could not resolve host",
+		"connection timed out",
+		"connection reset",
+		"connection refused",
+		"early eof",
+		"rpc failed",
+		"failed to connect to",
+		"the remote end hung up",
+		"i/o timeout",
+		"tls handshake timeout",
+		"status code: 502",
+		"status code: 503",
+		"status code: 504",
+		// Derived data cache and file locks
+		"failed to lock",
+		"ddc lock",
+		"is being used by another process",
+		"sharing violation",
+		// Shader compiler
+		"shadercompileworker crashed",
+		"shadercompileworker died",
+		"shadercompileworker terminated",
+		"shader compiling failed",
+	}
+
+	// authPatterns are the error messages of the rejected credentials, e.g. a git fetch with an expired token fails with
+	// "unable to access" and "rpc failed" too, so they are checked before the transient patterns
+	authPatterns = []string{
+		"returned error: 401",
+		"returned error: 403",
+		"http 401",
+		"http 403",
+		"status code: 401",
+		"status code: 403",
+		"authentication failed",
+		"permission denied (publickey)",
+	}
+
+	// infraPatterns are the error messages of the build node failures
+	infraPatterns = []string{
+		"command not found",
+		"working directory does not exist",
+		"no space left on device",
+		"not enough space on the disk",
+		"there is not enough space",
+		"out of memory",
+		"command timed out",
+		"failed to find engine version selector tool",
+		"is not installed on this node",
+		"invalid workspace",
+	}
+
+	// configPatterns are the error messages of the invalid job or tool configuration
+	configPatterns = []string{
+		"invalid job",
+		"job is nil",
+		"job release is nil",
+		"no release metadata",
+		"no package metadata",
+		"invalid command line",
+		"missing placeholder values",
+		"unused placeholder values",
+		"invalid buildcookrun arguments",
+		"invalid project",
+		"invalid map list",
+		"invalid package",
+		"did not match any file(s) known to git",
+		"invalid repository",
+		"invalid release tag",
+		"invalid ref",
+	}
+)
+
+// Classify returns the category of the error.
+func Classify(err error) config.FailureCategoryType {
+	if err == nil {
+		return config.FailureCategoryUnknown
+	}
+
+	// Use the category of the phase failure
+	var phaseError *Error
+	if errors.As(err, &phaseError) {
+		return phaseError.Category
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return config.FailureCategoryCancelled
+	}
+
+	// Classify the Automation Tool failures by the parsed output
+	var uatError *unreal.AutomationToolError
+	if errors.As(err, &uatError) && uatError.Summary != nil {
+		if category, ok := classifySummary(uatError.Summary); ok {
+			return category
+		}
+	}
+
+	// Classify the automation test failures, the failed tests are code failures and a filter matching no tests is a
+	// configuration failure
+	var testError *unreal.AutomationTestError
+	if errors.As(err, &testError) {
+		if testError.Report.Total() == 0 {
+			return config.FailureCategoryConfig
+		}
+		return config.FailureCategoryCode
+	}
+
+	// Classify the content validation failures, the findings are in the package content
+	var validationError *unreal.ValidationError
+	if errors.As(err, &validationError) {
+		return config.FailureCategoryContent
+	}
+
+	// Classify the server smoke test failures by the server log, a server failing to start is a code failure otherwise
+	var smokeTestError *unreal.SmokeTestError
+	if errors.As(err, &smokeTestError) {
+		if smokeTestError.Summary != nil {
+			if category, ok := classifySummary(smokeTestError.Summary); ok {
+				return category
+			}
+		}
+		return config.FailureCategoryCode
+	}
+
+	// Hung commands, e.g. a cook waiting for a DDC lock, usually pass on retry
+	var hungError *cmd.HungError
+	if errors.As(err, &hungError) {
+		return config.FailureCategoryTransient
+	}
+
+	message := strings.ToLower(err.Error())
+
+	// Rejected credentials do not pass on retry
+	if containsAny(message, authPatterns) {
+		return config.FailureCategoryConfig
+	}
+
+	if containsAny(message, transientPatterns) {
+		return config.FailureCategoryTransient
+	}
+
+	// Network errors of the API requests
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return config.FailureCategoryTransient
+	}
+
+	if containsAny(message, infraPatterns) {
+		return config.FailureCategoryInfra
+	}
+
+	if containsAny(message, configPatterns) {
+		return config.FailureCategoryConfig
+	}
+
+	return config.FailureCategoryUnknown
+}
+
+// classifySummary classifies the Automation Tool failure by the parsed output.
+func classifySummary(s *unreallog.Summary) (config.FailureCategoryType, bool) {
+	// Transient failures reported in the log take precedence as they cause the other errors
+	var messages []string
+	for _, e := range s.Errors() {
+		messages = append(messages, strings.ToLower(e.Message))
+	}
+	if s.Crash != nil {
+		messages = append(messages, strings.ToLower(strings.Join(s.Crash.Callstack, "\n")))
+	}
+	if containsAny(strings.Join(messages, "\n"), authPatterns) {
+		return config.FailureCategoryConfig, true
+	}
+	if containsAny(strings.Join(messages, "\n"), transientPatterns) {
+		return config.FailureCategoryTransient, true
+	}
+
+	switch {
+	case len(s.CompileErrors) > 0:
+		return config.FailureCategoryCode, true
+	case len(s.InitErrors) > 0:
+		return config.FailureCategoryConfig, true
+	case len(s.CookErrors) > 0 || s.Crash != nil:
+		return config.FailureCategoryContent, true
+	}
+
+	return config.FailureCategoryUnknown, false
+}
+
+// containsAny checks if the string contains any of the patterns.
+func containsAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package failure
+
+import (
+	"context"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"time"
+)
+
+const (
+	// PhaseSync is the phase updating the source code
+	PhaseSync = "sync"
+	// PhaseBuild is the phase building the project
+	PhaseBuild = "build"
+	// PhaseUpload is the phase uploading the results
+	PhaseUpload = "upload"
+)
+
+// Retry runs the function of the job processing phase and retries it according to the retry policy of the phase from
+// the shared configuration. Only transient failures are retried. The returned error is an Error with the category.
+func Retry(ctx context.Context, shared *config.SharedConfig, phase string, fn func() error) error {
+	policy := shared.Retry[phase]
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		category := Classify(err)
+		if category != config.FailureCategoryTransient || attempt >= policy.Attempts {
+			return &Error{Phase: phase, Category: category, Attempts: attempt, Err: err}
+		}
+
+		delay := time.Duration(policy.DelaySeconds) * time.Second
+		logger.Logger.Warningf("%s attempt %d of %d failed with a transient error, retrying in %s: %v", phase, attempt, policy.Attempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return &Error{Phase: phase, Category: config.FailureCategoryCancelled, Attempts: attempt, Err: err}
+		case <-time.After(delay):
+		}
+	}
+}
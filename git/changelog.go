@@ -0,0 +1,253 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// changelogGroups are the conventional commit types in the changelog order with their titles, the other types are
+// grouped as "other"
+var changelogGroups = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"other", "Other Changes"},
+}
+
+var (
+	// conventionalRegex matches the conventional commit subjects, e.g. "feat(lobby)!: add the party invites"
+	conventionalRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+	// issueKeyRegex matches the issue keys, e.g. "MV-123"
+	issueKeyRegex = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b`)
+)
+
+// ChangelogIssue is an issue referenced by a commit
+type ChangelogIssue struct {
+	Key string `json:"key"`           // Issue key, e.g. "MV-123"
+	Url string `json:"url,omitempty"` // Issue link
+}
+
+// ChangelogEntry is a commit in the changelog
+type ChangelogEntry struct {
+	Commit   string           `json:"commit"`
+	Type     string           `json:"type"`            // Conventional commit type, e.g. "feat", "other" for the non-conventional commits
+	Scope    string           `json:"scope,omitempty"` // Conventional commit scope, e.g. "lobby"
+	Subject  string           `json:"subject"`         // Commit subject without the type and the scope
+	Breaking bool             `json:"breaking"`        // Breaking change marked with "!" or "BREAKING CHANGE:"
+	Author   string           `json:"author"`
+	Date     string           `json:"date"` // Author date in the strict ISO 8601 format
+	Issues   []ChangelogIssue `json:"issues,omitempty"`
+}
+
+// ChangelogGroup is a group of the changelog entries of a type
+type ChangelogGroup struct {
+	Type    string           `json:"type"`
+	Title   string           `json:"title"`
+	Entries []ChangelogEntry `json:"entries"`
+}
+
+// Changelog is the list of the changes between two releases
+type Changelog struct {
+	From      string           `json:"from,omitempty"` // Previous release tag, empty for the first release
+	To        string           `json:"to"`             // Release code version
+	Commits   int              `json:"commits"`        // Number of the commits
+	Truncated bool             `json:"truncated"`      // The commits exceeding the limit are not included
+	Groups    []ChangelogGroup `json:"groups"`
+}
+
+// ChangelogOptions are the options of the changelog
+type ChangelogOptions struct {
+	TagPattern string // Pattern of the release tags used to find the previous release, e.g. "v*"
+	IssueUrl   string // Issue link with the {key} placeholder, e.g. "https://jira.example.com/browse/{key}"
+	MaxCommits int    // Maximum number of the commits, zero means no limit
+}
+
+// PreviousTag returns the closest tag matching the pattern reachable from the parent of the ref, empty if none.
+func PreviousTag(ctx context.Context, workdir string, ref string, pattern string) (string, error) {
+	// The first commit has no parent, there is no previous release
+	if _, err := RevParse(ctx, workdir, ref+"^"); err != nil {
+		return "", nil
+	}
+
+	tag, err := output(ctx, workdir, "describe --tags --abbrev=0 --match={pattern?} {rev}", map[string]string{"pattern": pattern, "rev": ref + "^"}, nil)
+	if err != nil {
+		// No tag is reachable, e.g. the first release
+		return "", nil
+	}
+
+	return tag, nil
+}
+
+// parseChangelogEntry parses the conventional commit subject and the issue keys of the subject and the body.
+func parseChangelogEntry(commit, author, date, subject, body string, issueUrl string) ChangelogEntry {
+	entry := ChangelogEntry{Commit: commit, Type: "other", Subject: subject, Author: author, Date: date}
+
+	if m := conventionalRegex.FindStringSubmatch(subject); m != nil {
+		entry.Type = strings.ToLower(m[1])
+		entry.Scope = m[2]
+		entry.Breaking = m[3] == "!"
+		entry.Subject = m[4]
+	}
+	if strings.Contains(body, "BREAKING CHANGE:") || strings.Contains(body, "BREAKING-CHANGE:") {
+		entry.Breaking = true
+	}
+
+	seen := map[string]bool{}
+	for _, key := range issueKeyRegex.FindAllString(subject+"\n"+body, -1) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		issue := ChangelogIssue{Key: key}
+		if issueUrl != "" {
+			issue.Url = strings.ReplaceAll(issueUrl, "{key}", key)
+		}
+		entry.Issues = append(entry.Issues, issue)
+	}
+
+	return entry
+}
+
+// GenerateChangelog lists the commits between the previous release tag and the ref, excluding the merge commits, and
+// groups them by the conventional commit type.
+func GenerateChangelog(ctx context.Context, workdir string, ref string, options ChangelogOptions) (*Changelog, error) {
+	from, err := PreviousTag(ctx, workdir, ref, options.TagPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := ref
+	if from != "" {
+		revisions = from + ".." + ref
+	}
+
+	// Request one commit over the limit to detect the truncation
+	maxCount := ""
+	if options.MaxCommits > 0 {
+		maxCount = strconv.Itoa(options.MaxCommits + 1)
+	}
+
+	// The fields are separated with the unit separator and the commits with the record separator
+	log, err := output(ctx, workdir, "log --no-merges --max-count={maxCount?} --format={format} {revisions}", map[string]string{
+		"maxCount":  maxCount,
+		"format":    "%H%x1f%an <%ae>%x1f%aI%x1f%s%x1f%b%x1e",
+		"revisions": revisions,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits %s: %w", revisions, err)
+	}
+
+	changelog := &Changelog{From: from, To: ref}
+
+	groups := map[string]*ChangelogGroup{}
+	for _, g := range changelogGroups {
+		groups[g.Type] = &ChangelogGroup{Type: g.Type, Title: g.Title}
+	}
+
+	for _, record := range strings.Split(log, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\r\n"), "\x1f")
+		if len(fields) < 5 {
+			continue
+		}
+
+		if options.MaxCommits > 0 && changelog.Commits == options.MaxCommits {
+			changelog.Truncated = true
+			break
+		}
+		changelog.Commits++
+
+		entry := parseChangelogEntry(fields[0], fields[1], fields[2], fields[3], fields[4], options.IssueUrl)
+		group, ok := groups[entry.Type]
+		if !ok {
+			group = groups["other"]
+		}
+		group.Entries = append(group.Entries, entry)
+	}
+
+	for _, g := range changelogGroups {
+		if len(groups[g.Type].Entries) > 0 {
+			changelog.Groups = append(changelog.Groups, *groups[g.Type])
+		}
+	}
+
+	return changelog, nil
+}
+
+// Markdown renders the changelog as markdown, e.g. for the launcher "what's new" page.
+func (c *Changelog) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# What's new in %s\n", c.To)
+	if c.From != "" {
+		fmt.Fprintf(&b, "\nChanges since %s.\n", c.From)
+	}
+
+	for _, g := range c.Groups {
+		fmt.Fprintf(&b, "\n## %s\n\n", g.Title)
+		for _, e := range g.Entries {
+			b.WriteString("- ")
+			if e.Breaking {
+				b.WriteString("**BREAKING** ")
+			}
+			if e.Scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", e.Scope)
+			}
+			// The issue keys of the subject are linked in place in a single pass, so a key is never replaced inside another
+			// key, e.g. "MV-1" in "MV-12", or inside an inserted link
+			urls := map[string]string{}
+			for _, i := range e.Issues {
+				urls[i.Key] = i.Url
+			}
+			inSubject := map[string]bool{}
+			subject := issueKeyRegex.ReplaceAllStringFunc(e.Subject, func(key string) string {
+				inSubject[key] = true
+				if urls[key] == "" {
+					return key
+				}
+				return fmt.Sprintf("[%s](%s)", key, urls[key])
+			})
+
+			// The issue keys of the body are appended
+			for _, i := range e.Issues {
+				switch {
+				case inSubject[i.Key]:
+				case i.Url != "":
+					subject += fmt.Sprintf(" ([%s](%s))", i.Key, i.Url)
+				default:
+					subject += fmt.Sprintf(" (%s)", i.Key)
+				}
+			}
+			b.WriteString(subject)
+			fmt.Fprintf(&b, " (%.7s)\n", e.Commit)
+		}
+	}
+
+	if c.Truncated {
+		fmt.Fprintf(&b, "\nOnly the latest %d commits are listed.\n", c.Commits)
+	}
+
+	return b.String()
+}
+
+// JSON renders the changelog as indented JSON, the author emails are not HTML escaped.
+func (c *Changelog) JSON() ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,219 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SubmoduleProvenance is the commit a submodule was checked out at
+type SubmoduleProvenance struct {
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	State  string `json:"state,omitempty"` // "uninitialized", "modified" or "conflict" if the submodule does not match the superproject
+}
+
+// Provenance records which source a build came from
+type Provenance struct {
+	Ref               string                `json:"ref"`                     // Checked out ref, e.g. the release code version tag
+	Commit            string                `json:"commit"`                  // Commit SHA
+	Tag               string                `json:"tag,omitempty"`           // Tag name if the ref is a tag
+	TagObject         string                `json:"tagObject,omitempty"`     // Tag object SHA of an annotated tag
+	Annotated         bool                  `json:"annotated"`               // The tag is an annotated tag
+	Signed            bool                  `json:"signed"`                  // The tag carries a signature
+	SignatureType     string                `json:"signatureType,omitempty"` // Signature type, "gpg" or "ssh"
+	Verified          bool                  `json:"verified"`                // The tag signature was verified
+	Tagger            string                `json:"tagger,omitempty"`        // Tagger of an annotated tag, e.g. "Name <email>"
+	Author            string                `json:"author"`                  // Commit author, e.g. "Name <email>"
+	AuthorDate        string                `json:"authorDate"`              // Commit author date in the strict ISO 8601 format
+	Committer         string                `json:"committer"`               // Committer, e.g. "Name <email>"
+	CommitDate        string                `json:"commitDate"`              // Commit date in the strict ISO 8601 format
+	Subject           string                `json:"subject"`                 // Commit subject
+	Submodules        []SubmoduleProvenance `json:"submodules,omitempty"`    // Submodule commits
+	LfsObjects        int                   `json:"lfsObjects"`              // Number of the LFS files in the worktree
+	LfsObjectsFetched int                   `json:"lfsObjectsFetched"`       // Number of the LFS files with the content checked out
+}
+
+// ProvenanceOptions are the options of the provenance
+type ProvenanceOptions struct {
+	Lfs            bool   // Count the LFS objects
+	VerifyTag      bool   // Require a signed tag and verify its signature
+	AllowedSigners string // Allowed signers file used to verify the SSH signatures, the GPG signatures are verified with the keyring
+}
+
+// String returns a short description of the provenance used in the job status, e.g.
+// "built from v1.2.0 at 3f2a... (signed tag, verified)".
+func (p *Provenance) String() string {
+	kind := "lightweight tag"
+	switch {
+	case p.Tag == "":
+		kind = "not a tag"
+	case p.Signed && p.Verified:
+		kind = "signed tag, verified"
+	case p.Signed:
+		kind = "signed tag"
+	case p.Annotated:
+		kind = "annotated tag"
+	}
+	return fmt.Sprintf("built from %s at %s (%s)", p.Ref, p.Commit, kind)
+}
+
+// readTag reads the tag object of an annotated tag: the tagger and the signature.
+func readTag(ctx context.Context, workdir string, p *Provenance) error {
+	content, err := output(ctx, workdir, "cat-file tag {object}", map[string]string{"object": p.TagObject}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read tag %s: %w", p.Tag, err)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		// The header ends with an empty line, e.g. "tagger Name <email> 1680000000 +0000"
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "tagger ") {
+			fields := strings.Fields(strings.TrimPrefix(line, "tagger "))
+			if len(fields) > 2 {
+				fields = fields[:len(fields)-2]
+			}
+			p.Tagger = strings.Join(fields, " ")
+		}
+	}
+
+	switch {
+	case strings.Contains(content, "-----BEGIN PGP SIGNATURE-----"):
+		p.Signed, p.SignatureType = true, "gpg"
+	case strings.Contains(content, "-----BEGIN SSH SIGNATURE-----"):
+		p.Signed, p.SignatureType = true, "ssh"
+	}
+
+	return nil
+}
+
+// VerifyTag verifies the tag signature, the SSH signatures are verified against the allowed signers file and the GPG
+// signatures with the keyring.
+func VerifyTag(ctx context.Context, workdir string, tag string, allowedSigners string) error {
+	var config []string
+	if allowedSigners != "" {
+		config = []string{"-c", "gpg.ssh.allowedSignersFile=" + allowedSigners}
+	}
+
+	if _, err := output(ctx, workdir, "{config...} verify-tag {tag}", map[string]string{"tag": tag}, map[string][]string{"config": config}); err != nil {
+		return fmt.Errorf("invalid release tag %s: signature verification failed: %w", tag, err)
+	}
+
+	return nil
+}
+
+// readSubmodules reads the commits of the submodules recursively.
+func readSubmodules(ctx context.Context, workdir string) ([]SubmoduleProvenance, error) {
+	status, err := output(ctx, workdir, "submodule status --recursive", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submodules: %w", err)
+	}
+
+	var submodules []SubmoduleProvenance
+	for _, line := range strings.Split(status, "\n") {
+		// e.g. " 3f2a... Plugins/Sub (heads/main)", the first character is the state
+		if len(line) < 2 {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) < 2 {
+			continue
+		}
+
+		submodule := SubmoduleProvenance{Commit: fields[0], Path: fields[1]}
+		switch line[0] {
+		case '-':
+			submodule.State = "uninitialized"
+		case '+':
+			submodule.State = "modified"
+		case 'U':
+			submodule.State = "conflict"
+		}
+		submodules = append(submodules, submodule)
+	}
+
+	return submodules, nil
+}
+
+// countLfsObjects counts the LFS files of the worktree and the ones with the content checked out.
+func countLfsObjects(ctx context.Context, workdir string) (total int, fetched int, err error) {
+	files, err := output(ctx, workdir, "lfs ls-files", nil, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list LFS files: %w", err)
+	}
+
+	for _, line := range strings.Split(files, "\n") {
+		// e.g. "4d7a214614 * Content/Maps/Lobby.umap", "-" marks a pointer file
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		total++
+		if fields[1] == "*" {
+			fetched++
+		}
+	}
+
+	return total, fetched, nil
+}
+
+// ReadProvenance resolves the commit, the tag, the author and the date of the checked out ref, the submodule commits and
+// the LFS object count. If required, the tag must be signed and its signature is verified.
+func ReadProvenance(ctx context.Context, workdir string, ref string, options ProvenanceOptions) (*Provenance, error) {
+	p := &Provenance{Ref: ref}
+
+	var err error
+	if p.Commit, err = RevParse(ctx, workdir, ref); err != nil {
+		return nil, err
+	}
+
+	// Check if the ref is a tag and if it is an annotated tag
+	if object, err := output(ctx, workdir, "rev-parse --verify --quiet {tag}", map[string]string{"tag": "refs/tags/" + ref}, nil); err == nil {
+		p.Tag = ref
+
+		kind, err := output(ctx, workdir, "cat-file -t {object}", map[string]string{"object": object}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tag %s: %w", ref, err)
+		}
+
+		if kind == "tag" {
+			p.Annotated, p.TagObject = true, object
+			if err = readTag(ctx, workdir, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Read the commit author, committer and subject
+	info, err := output(ctx, workdir, "show --no-patch --format={format} {commit}", map[string]string{"format": "%an <%ae>%n%aI%n%cn <%ce>%n%cI%n%s", "commit": p.Commit}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", p.Commit, err)
+	}
+	if lines := strings.SplitN(info, "\n", 5); len(lines) == 5 {
+		p.Author, p.AuthorDate, p.Committer, p.CommitDate, p.Subject = lines[0], lines[1], lines[2], lines[3], lines[4]
+	}
+
+	if options.VerifyTag {
+		if !p.Signed {
+			return nil, fmt.Errorf("invalid release tag %s: tag is not signed", ref)
+		}
+		if err = VerifyTag(ctx, workdir, p.Tag, options.AllowedSigners); err != nil {
+			return nil, err
+		}
+		p.Verified = true
+	}
+
+	if p.Submodules, err = readSubmodules(ctx, workdir); err != nil {
+		return nil, err
+	}
+
+	if options.Lfs {
+		if p.LfsObjects, p.LfsObjectsFetched, err = countLfsObjects(ctx, workdir); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
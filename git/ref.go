@@ -0,0 +1,132 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// nearestTagsListed is the number of the nearest tags listed when a ref is not found
+const nearestTagsListed = 5
+
+// shaRegex matches full and abbreviated commit SHAs
+var shaRegex = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+const (
+	// RefKindTag is a tag ref
+	RefKindTag = "tag"
+	// RefKindBranch is a branch ref, the remote branch HEAD is checked out
+	RefKindBranch = "branch"
+	// RefKindCommit is a commit SHA
+	RefKindCommit = "commit"
+)
+
+// ResolvedRef is a ref resolved to a commit
+type ResolvedRef struct {
+	Name   string `json:"name"`   // Requested ref, e.g. "v1.2.0", "development" or "3f2a9c1"
+	Kind   string `json:"kind"`   // Kind of the ref, "tag", "branch" or "commit"
+	Ref    string `json:"ref"`    // Revision checked out, e.g. "v1.2.0", "origin/development" or the full commit SHA
+	Commit string `json:"commit"` // Commit SHA
+}
+
+// ResolveRef resolves the tag, the remote or local branch or the commit SHA to a commit, in that order. If nothing
+// matches, the error lists the nearest matching tags.
+func ResolveRef(ctx context.Context, workdir string, name string) (*ResolvedRef, error) {
+	if name == "" {
+		return nil, fmt.Errorf("invalid ref: empty ref")
+	}
+
+	candidates := []struct {
+		kind string
+		ref  string
+		rev  string
+	}{
+		{RefKindTag, name, "refs/tags/" + name},
+		{RefKindBranch, "origin/" + name, "refs/remotes/origin/" + name},
+		{RefKindBranch, name, "refs/heads/" + name},
+	}
+	for _, c := range candidates {
+		if commit, err := output(ctx, workdir, "rev-parse --verify --quiet {rev}", map[string]string{"rev": c.rev + "^{commit}"}, nil); err == nil {
+			return &ResolvedRef{Name: name, Kind: c.kind, Ref: c.ref, Commit: commit}, nil
+		}
+	}
+
+	if shaRegex.MatchString(name) {
+		if commit, err := RevParse(ctx, workdir, name); err == nil {
+			return &ResolvedRef{Name: name, Kind: RefKindCommit, Ref: commit, Commit: commit}, nil
+		}
+	}
+
+	// Help to spot a typo or a missing tag
+	nearest, err := NearestTags(ctx, workdir, name, nearestTagsListed)
+	if err != nil || len(nearest) == 0 {
+		return nil, fmt.Errorf("invalid ref %s: no tag, branch or commit found", name)
+	}
+
+	return nil, fmt.Errorf("invalid ref %s: no tag, branch or commit found, nearest tags: %s", name, strings.Join(nearest, ", "))
+}
+
+// NearestTags returns up to n tags closest to the name by the edit distance, the later versions first on ties.
+func NearestTags(ctx context.Context, workdir string, name string, n int) ([]string, error) {
+	list, err := output(ctx, workdir, "tag --list --sort=-version:refname", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if list == "" {
+		return nil, nil
+	}
+
+	tags := strings.Split(list, "\n")
+	distances := make(map[string]int, len(tags))
+	for _, t := range tags {
+		distances[t] = editDistance(strings.ToLower(name), strings.ToLower(t))
+	}
+
+	// The stable sort keeps the version order for the tags at the same distance
+	sort.SliceStable(tags, func(i, j int) bool {
+		return distances[tags[i]] < distances[tags[j]]
+	})
+
+	if len(tags) > n {
+		tags = tags[:n]
+	}
+
+	return tags, nil
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// min returns the smallest of the values.
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,176 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"l7-cloud-builder/secrets"
+	"math"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RepoOptions are the options of the repository bootstrap
+type RepoOptions struct {
+	Url       string // Remote URL, e.g. "https://git.example.com/project.git" or a local bare repository path
+	Branch    string // Branch checked out after cloning, the remote HEAD is used if empty
+	Filter    string // Partial clone filter, e.g. "blob:none" for a blobless clone
+	Depth     int    // Shallow clone depth, zero clones the full history
+	Reference string // Reference repository sharing its objects with the clone, e.g. a mirror shared by the workspaces of the node
+	Worktree  string // Main repository the directory is added to as a worktree instead of cloning, e.g. for parallel jobs
+}
+
+// IsRepo checks if the directory is the top level of a git repository or a worktree.
+func IsRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// isEmptyDir checks if the directory does not exist or is empty.
+func isEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// tokenPrefixes are the prefixes of the access tokens passed as the user name of the remote URL, e.g. GitHub and GitLab
+// personal access tokens
+var tokenPrefixes = []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_", "glpat-"}
+
+// looksLikeToken checks if the user name of the remote URL is an access token: a known token prefix, or a long random
+// string. The plain user names, e.g. "git", "builder" or "x-access-token", are not tokens.
+func looksLikeToken(name string) bool {
+	for _, prefix := range tokenPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	if len(name) < 20 {
+		return false
+	}
+
+	// Shannon entropy in bits per character, the random tokens are above 3.5, the words are below
+	counts := map[rune]int{}
+	for _, r := range name {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(len(name))
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy >= 3.5
+}
+
+// redactUrlPassword registers the credentials of the remote URL as secrets, so they are redacted from the command lines
+// and the output: the password, or the user name if it is an access token.
+func redactUrlPassword(url string) {
+	u, err := neturl.Parse(url)
+	if err != nil || u.User == nil {
+		return
+	}
+	if password, ok := u.User.Password(); ok {
+		secrets.Register(password)
+	} else if looksLikeToken(u.User.Username()) {
+		// A token is often passed as the user name
+		secrets.Register(u.User.Username())
+	}
+}
+
+// Clone clones the remote into the directory, optionally as a blobless partial clone, a shallow clone or borrowing the
+// objects of a reference repository.
+func Clone(ctx context.Context, dir string, options RepoOptions) error {
+	redactUrlPassword(options.Url)
+
+	// Clone from the parent directory, the directory is created by git
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", parent, err)
+	}
+
+	depth := ""
+	if options.Depth > 0 {
+		depth = strconv.Itoa(options.Depth)
+	}
+
+	placeholders := map[string]string{
+		"url":       options.Url,
+		"dir":       dir,
+		"branch":    options.Branch,
+		"filter":    options.Filter,
+		"depth":     depth,
+		"reference": options.Reference,
+	}
+
+	if _, err := output(ctx, parent, "clone --quiet --branch={branch?} --filter={filter?} --depth={depth?} --reference-if-able={reference?} {url} {dir}", placeholders, nil); err != nil {
+		return fmt.Errorf("failed to clone %s: %w", options.Url, err)
+	}
+
+	return nil
+}
+
+// AddWorktree adds a detached worktree of the main repository at the directory, the worktrees share the objects and
+// the refs of the main repository, so parallel jobs can check out different refs.
+func AddWorktree(ctx context.Context, repoDir string, dir string, ref string) error {
+	// Forget the worktrees whose directories were removed, they block adding a worktree at the same path
+	if _, err := output(ctx, repoDir, "worktree prune", nil, nil); err != nil {
+		return fmt.Errorf("failed to prune worktrees of %s: %w", repoDir, err)
+	}
+
+	if _, err := output(ctx, repoDir, "worktree add --force --detach --quiet {dir} {ref?}", map[string]string{"dir": dir, "ref": ref}, nil); err != nil {
+		return fmt.Errorf("failed to add worktree %s of %s: %w", dir, repoDir, err)
+	}
+
+	return nil
+}
+
+// EnsureRepo makes sure the directory is a git repository, the remote is cloned or a worktree of the main repository is
+// added if the directory is missing or empty. Returns true if the repository was created.
+func EnsureRepo(ctx context.Context, dir string, options RepoOptions) (bool, error) {
+	if IsRepo(dir) {
+		return false, nil
+	}
+
+	// Do not clone over the existing files
+	empty, err := isEmptyDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	if !empty {
+		return false, fmt.Errorf("invalid repository %s: directory is not empty and is not a git repository", dir)
+	}
+
+	if options.Worktree != "" {
+		// Bootstrap the main repository first
+		main := options
+		main.Worktree = ""
+		if _, err = EnsureRepo(ctx, options.Worktree, main); err != nil {
+			return false, err
+		}
+
+		if err = AddWorktree(ctx, options.Worktree, dir, options.Branch); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if options.Url == "" {
+		return false, fmt.Errorf("invalid repository %s: directory does not exist and no remote is configured", dir)
+	}
+
+	if err = Clone(ctx, dir, options); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
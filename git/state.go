@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// State is the current state of a worktree, e.g. shown by the workspace status command
+type State struct {
+	Dir               string   `json:"dir"`
+	Ref               string   `json:"ref"`                // Checked out branch, tag or remote branch, the commit SHA if none points to HEAD
+	RefKind           string   `json:"refKind"`            // Kind of the checked out ref, "tag", "branch" or "commit"
+	Detached          bool     `json:"detached"`           // HEAD is detached, e.g. after a sync
+	Commit            string   `json:"commit"`             // Commit SHA of HEAD
+	Subject           string   `json:"subject"`            // Commit subject of HEAD
+	Dirty             []string `json:"dirty,omitempty"`    // Modified and untracked files, e.g. " M Config/DefaultGame.ini"
+	DirtyCount        int      `json:"dirtyCount"`         // Number of the modified and untracked files
+	Lfs               bool     `json:"lfs"`                // The LFS objects were counted
+	LfsError          string   `json:"lfsError,omitempty"` // Reason the LFS objects could not be counted, e.g. git lfs is not installed
+	LfsObjects        int      `json:"lfsObjects"`         // Number of the LFS files in the worktree
+	LfsObjectsFetched int      `json:"lfsObjectsFetched"`  // Number of the LFS files with the content checked out
+}
+
+// headRef returns the ref HEAD points to: the current branch, else the tag, else the remote branch, else the commit.
+func headRef(ctx context.Context, workdir string, commit string) (ref string, kind string, detached bool) {
+	if branch, err := output(ctx, workdir, "symbolic-ref --short --quiet HEAD", nil, nil); err == nil && branch != "" {
+		return branch, RefKindBranch, false
+	}
+
+	if tag, err := output(ctx, workdir, "describe --tags --exact-match HEAD", nil, nil); err == nil && tag != "" {
+		return tag, RefKindTag, true
+	}
+
+	// The branches are checked out detached at the remote branch HEAD by the sync
+	if remotes, err := output(ctx, workdir, "for-each-ref --points-at=HEAD --format=%(refname:short) refs/remotes/origin", nil, nil); err == nil && remotes != "" {
+		for _, remote := range strings.Split(remotes, "\n") {
+			if remote != "origin/HEAD" && remote != "origin" {
+				return remote, RefKindBranch, true
+			}
+		}
+	}
+
+	return commit, RefKindCommit, true
+}
+
+// ReadState reads the checked out ref and commit, the modified and untracked files and optionally the LFS object count.
+// A missing git lfs is reported in the state instead of failing.
+func ReadState(ctx context.Context, workdir string, lfs bool) (*State, error) {
+	s := &State{Dir: workdir}
+
+	var err error
+	if s.Commit, err = RevParse(ctx, workdir, "HEAD"); err != nil {
+		return nil, err
+	}
+	s.Ref, s.RefKind, s.Detached = headRef(ctx, workdir, s.Commit)
+
+	if s.Subject, err = output(ctx, workdir, "show --no-patch --format=%s HEAD", nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", s.Commit, err)
+	}
+
+	dirty, err := Status(ctx, workdir)
+	if err != nil {
+		return nil, err
+	}
+	s.DirtyCount = len(dirty)
+	if len(dirty) > workspaceDirtyListed {
+		dirty = dirty[:workspaceDirtyListed]
+	}
+	s.Dirty = dirty
+
+	if lfs {
+		if _, err = output(ctx, workdir, "lfs version", nil, nil); err != nil {
+			s.LfsError = "git lfs is not installed on this node"
+		} else if s.LfsObjects, s.LfsObjectsFetched, err = countLfsObjects(ctx, workdir); err != nil {
+			s.LfsError = err.Error()
+		} else {
+			s.Lfs = true
+		}
+	}
+
+	return s, nil
+}
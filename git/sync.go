@@ -0,0 +1,195 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"l7-cloud-builder/cmd"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SyncStepFetch fetches the refs and tags from the remote
+	SyncStepFetch = "fetch"
+	// SyncStepResolve resolves the ref to a tag, a branch or a commit
+	SyncStepResolve = "resolve"
+	// SyncStepCheckout checks out the ref
+	SyncStepCheckout = "checkout"
+	// SyncStepSubmodules updates the submodules recursively
+	SyncStepSubmodules = "submodules"
+	// SyncStepLfsFetch fetches the LFS objects matching the include and exclude patterns
+	SyncStepLfsFetch = "lfs fetch"
+	// SyncStepLfsCheckout replaces the LFS pointer files with the fetched objects
+	SyncStepLfsCheckout = "lfs checkout"
+	// SyncStepVerify verifies HEAD matches the ref
+	SyncStepVerify = "verify"
+)
+
+// SyncError is returned when a step of the sync fails
+type SyncError struct {
+	Step string // Failed step, e.g. "lfs fetch"
+	Ref  string // Synced ref
+	Err  error  // Error returned by the step
+}
+
+// Error returns the error message naming the failed step.
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("failed to sync %s, %s failed: %v", e.Ref, e.Step, e.Err)
+}
+
+// Unwrap returns the error returned by the step.
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// SyncOptions are the options of the sync
+type SyncOptions struct {
+	Submodules bool     // Update the submodules recursively
+	Lfs        bool     // Fetch and checkout the LFS objects
+	LfsInclude []string // LFS paths to fetch, e.g. "Content/**", all paths are fetched if empty
+	LfsExclude []string // LFS paths to skip, e.g. "Content/ClientOnly/**"
+}
+
+// outputWithEnv runs the git command with the environment variables and returns its standard output without the
+// trailing line breaks.
+func outputWithEnv(ctx context.Context, workdir string, env map[string]string, cmdline string, placeholders map[string]string, lists map[string][]string) (string, error) {
+	var git = &cmd.Cmd{
+		Command:      "git",
+		CommandLine:  cmdline,
+		WorkingDir:   workdir,
+		Env:          env,
+		Placeholders: placeholders,
+		Lists:        lists,
+	}
+
+	if err := git.Run(ctx); err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(git.Output), "\r\n"), nil
+}
+
+// submodulePatterns returns the LFS patterns of the project repo applying to the submodule, relative to the submodule:
+// the patterns covering the whole submodule, the patterns inside the submodule path without the path prefix and the
+// patterns matching in any directory, e.g. "*.uasset" or "**/Textures/**".
+func submodulePatterns(path string, patterns []string) []string {
+	var result []string
+	for _, p := range patterns {
+		switch {
+		case strings.HasSuffix(p, "/**") && strings.HasPrefix(path+"/", strings.TrimSuffix(p, "**")):
+			// The pattern covers the whole submodule, e.g. "Plugins/**"
+			result = append(result, "**")
+		case strings.HasPrefix(p, path+"/"):
+			result = append(result, strings.TrimPrefix(p, path+"/"))
+		case !strings.Contains(p, "/") || strings.HasPrefix(p, "**/"):
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// lfsSync fetches the LFS objects matching the include and exclude patterns and checks them out.
+func lfsSync(ctx context.Context, workdir string, ref string, include []string, exclude []string) error {
+	placeholders := map[string]string{
+		"include": strings.Join(include, ","),
+		"exclude": strings.Join(exclude, ","),
+	}
+
+	if _, err := output(ctx, workdir, "lfs fetch --include={include?} --exclude={exclude?}", placeholders, nil); err != nil {
+		return &SyncError{Step: SyncStepLfsFetch, Ref: ref, Err: err}
+	}
+
+	// Only the fetched objects are checked out, the excluded files stay pointer files
+	if _, err := output(ctx, workdir, "lfs checkout", nil, nil); err != nil {
+		return &SyncError{Step: SyncStepLfsCheckout, Ref: ref, Err: err}
+	}
+
+	return nil
+}
+
+// lfsSyncSubmodules fetches and checks out the LFS objects of the submodules, the submodules are checked out without
+// them. The project repo patterns are applied relative to every submodule, the submodules outside the include patterns
+// are skipped.
+func lfsSyncSubmodules(ctx context.Context, workdir string, ref string, options SyncOptions) error {
+	// List the submodule paths relative to the project repo, the nested submodules included
+	paths, err := output(ctx, workdir, "submodule foreach --recursive --quiet {script}", map[string]string{"script": `echo "$displaypath"`}, nil)
+	if err != nil {
+		return &SyncError{Step: SyncStepSubmodules, Ref: ref, Err: err}
+	}
+
+	for _, path := range strings.Split(paths, "\n") {
+		path = strings.TrimRight(path, "\r")
+		if path == "" {
+			continue
+		}
+
+		include := submodulePatterns(path, options.LfsInclude)
+		if len(options.LfsInclude) > 0 && len(include) == 0 {
+			continue
+		}
+
+		if err = lfsSync(ctx, filepath.Join(workdir, filepath.FromSlash(path)), ref, include, submodulePatterns(path, options.LfsExclude)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sync fetches the refs and tags, resolves the tag, branch or commit SHA and checks it out detached, updates the
+// submodules recursively and fetches and checks out the LFS objects matching the include and exclude patterns in the
+// project repo and in the submodules, then verifies HEAD matches the ref. Returns the resolved ref or a SyncError naming
+// the failed step.
+func Sync(ctx context.Context, workdir string, ref string, options SyncOptions) (*ResolvedRef, error) {
+	// The LFS objects are fetched with the patterns after the checkout, skip downloading all of them while checking out
+	var env map[string]string
+	if options.Lfs {
+		env = map[string]string{"GIT_LFS_SKIP_SMUDGE": "1"}
+
+		if _, err := output(ctx, workdir, "lfs version", nil, nil); err != nil {
+			return nil, &SyncError{Step: SyncStepLfsFetch, Ref: ref, Err: fmt.Errorf("git lfs is not installed on this node: %w", err)}
+		}
+	}
+
+	if _, err := outputWithEnv(ctx, workdir, env, "fetch --tags --prune --force --quiet", nil, nil); err != nil {
+		return nil, &SyncError{Step: SyncStepFetch, Ref: ref, Err: err}
+	}
+
+	resolved, err := ResolveRef(ctx, workdir, ref)
+	if err != nil {
+		return nil, &SyncError{Step: SyncStepResolve, Ref: ref, Err: err}
+	}
+
+	if _, err := outputWithEnv(ctx, workdir, env, "checkout --force --detach --quiet {ref}", map[string]string{"ref": resolved.Ref}, nil); err != nil {
+		return nil, &SyncError{Step: SyncStepCheckout, Ref: ref, Err: err}
+	}
+
+	if options.Submodules {
+		// Pick up the submodule URL changes of the ref before updating
+		if _, err := outputWithEnv(ctx, workdir, env, "submodule sync --recursive --quiet", nil, nil); err != nil {
+			return nil, &SyncError{Step: SyncStepSubmodules, Ref: ref, Err: err}
+		}
+
+		if _, err := outputWithEnv(ctx, workdir, env, "submodule update --init --recursive --force --quiet", nil, nil); err != nil {
+			return nil, &SyncError{Step: SyncStepSubmodules, Ref: ref, Err: err}
+		}
+	}
+
+	if options.Lfs {
+		if err = lfsSync(ctx, workdir, ref, options.LfsInclude, options.LfsExclude); err != nil {
+			return nil, err
+		}
+
+		if options.Submodules {
+			if err = lfsSyncSubmodules(ctx, workdir, ref, options); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err = VerifyHead(ctx, workdir, resolved.Ref); err != nil {
+		return nil, &SyncError{Step: SyncStepVerify, Ref: ref, Err: err}
+	}
+
+	return resolved, nil
+}
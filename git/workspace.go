@@ -0,0 +1,119 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// workspaceDirtyListed is the number of the dirty files recorded in the workspace report
+const workspaceDirtyListed = 50
+
+// Workspace is the result of the workspace hygiene step, recorded in the job report
+type Workspace struct {
+	Dirty      []string `json:"dirty,omitempty"`   // Modified and untracked files found before the reset, e.g. " M Config/DefaultGame.ini"
+	DirtyCount int      `json:"dirtyCount"`        // Number of the modified and untracked files found before the reset
+	Kept       []string `json:"kept"`              // Ignored files and directories kept by the clean, e.g. "DerivedDataCache"
+	Ref        string   `json:"ref,omitempty"`     // Checked out ref, e.g. the release code version tag
+	RefKind    string   `json:"refKind,omitempty"` // Kind of the checked out ref, "tag", "branch" or "commit"
+	Commit     string   `json:"commit,omitempty"`
+}
+
+// output runs the git command and returns its standard output without the trailing line breaks.
+func output(ctx context.Context, workdir string, cmdline string, placeholders map[string]string, lists map[string][]string) (string, error) {
+	return outputWithEnv(ctx, workdir, nil, cmdline, placeholders, lists)
+}
+
+// Status returns the modified and untracked files of the worktree in the porcelain format, e.g. "?? Content/New.uasset".
+func Status(ctx context.Context, workdir string) ([]string, error) {
+	out, err := output(ctx, workdir, "status --porcelain", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the worktree status: %w", err)
+	}
+
+	if out == "" {
+		return nil, nil
+	}
+
+	return strings.Split(out, "\n"), nil
+}
+
+// Reset resets the index and the worktree to HEAD, discarding the local changes.
+func Reset(ctx context.Context, workdir string) error {
+	if _, err := output(ctx, workdir, "reset --hard --quiet", nil, nil); err != nil {
+		return fmt.Errorf("failed to reset the worktree: %w", err)
+	}
+
+	return nil
+}
+
+// Clean removes the untracked and ignored files of the worktree, except the ones matching the keep patterns, e.g.
+// "DerivedDataCache" or "Intermediate", so incremental builds still work.
+func Clean(ctx context.Context, workdir string, keep []string) error {
+	var excludes []string
+	for _, k := range keep {
+		excludes = append(excludes, "-e", k)
+	}
+
+	if _, err := output(ctx, workdir, "clean -ffdx --quiet {excludes...}", nil, map[string][]string{"excludes": excludes}); err != nil {
+		return fmt.Errorf("failed to clean the worktree: %w", err)
+	}
+
+	return nil
+}
+
+// RevParse returns the commit the ref points to, e.g. the commit of an annotated tag.
+func RevParse(ctx context.Context, workdir string, ref string) (string, error) {
+	commit, err := output(ctx, workdir, "rev-parse --verify --quiet {rev}", map[string]string{"rev": ref + "^{commit}"}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+
+	return commit, nil
+}
+
+// CleanWorkspace detects the local changes and leftovers of the previous jobs, resets the worktree hard and removes
+// the untracked and ignored files except the kept ones. Returns the workspace report.
+func CleanWorkspace(ctx context.Context, workdir string, keep []string) (*Workspace, error) {
+	w := &Workspace{Kept: keep}
+
+	dirty, err := Status(ctx, workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	w.DirtyCount = len(dirty)
+	if len(dirty) > workspaceDirtyListed {
+		dirty = dirty[:workspaceDirtyListed]
+	}
+	w.Dirty = dirty
+
+	if err = Reset(ctx, workdir); err != nil {
+		return nil, err
+	}
+
+	if err = Clean(ctx, workdir, keep); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// VerifyHead checks HEAD points to the same commit as the ref after the checkout, returns the commit.
+func VerifyHead(ctx context.Context, workdir string, ref string) (string, error) {
+	head, err := RevParse(ctx, workdir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := RevParse(ctx, workdir, ref)
+	if err != nil {
+		return "", err
+	}
+
+	if head != commit {
+		return "", fmt.Errorf("invalid workspace: HEAD %s does not match %s commit %s", head, ref, commit)
+	}
+
+	return head, nil
+}
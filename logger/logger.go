@@ -25,4 +25,7 @@ func init() {
 		Hooks: make(logrus.LevelHooks),
 		Level: logrus.DebugLevel,
 	}
+
+	// Redact secret values from all log entries, added first so the hooks added later receive the redacted entry.
+	Logger.AddHook(&redactHook{})
 }
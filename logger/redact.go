@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"l7-cloud-builder/secrets"
+)
+
+// redactHook redacts registered secret values from the log entry message and fields before the entry is formatted or
+// passed to the hooks added after it (e.g. the Clickhouse hook).
+type redactHook struct{}
+
+// Levels returns all log levels, every entry must be redacted.
+func (h *redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the entry message and string or error fields.
+func (h *redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = secrets.Redact(entry.Message)
+
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = secrets.Redact(v)
+		case error:
+			entry.Data[key] = secrets.Redact(v.Error())
+		case fmt.Stringer:
+			entry.Data[key] = secrets.Redact(v.String())
+		}
+	}
+
+	return nil
+}
@@ -15,8 +15,16 @@ import (
 	"l7-cloud-builder/database"
 	"l7-cloud-builder/logger"
 	"l7-cloud-builder/processing"
+	"l7-cloud-builder/secrets"
+	"l7-cloud-builder/unreal"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 var rootCmd *cobra.Command
@@ -62,19 +70,20 @@ func init() {
 		config.Api.CredentialsPath = ".credentials"
 	}
 
-	// Load API credentials.
+	// Load API credentials, the password can be supplied via API_PASSWORD or read from the file at API_PASSWORD_FILE.
 	config.Api.Email = os.Getenv("API_EMAIL")
-	config.Api.Password = os.Getenv("API_PASSWORD")
+	config.Api.Password, err = secrets.Getenv("API_PASSWORD")
+	if err != nil {
+		logger.Logger.Fatalln(err)
+	}
 	if config.Api.Email == "" || config.Api.Password == "" {
 		logger.Logger.Infof("loading credentials from file: %s\n", config.Api.CredentialsPath)
-		b, err := os.ReadFile(config.Api.CredentialsPath)
+		credentials, err := secrets.LoadCredentials(config.Api.CredentialsPath)
 		if err != nil {
 			logger.Logger.Fatalln(err)
 		}
-		c := string(b)
-		t := strings.Split(c, ":")
-		config.Api.Email = t[0]
-		config.Api.Password = t[1]
+		config.Api.Email = credentials.Email
+		config.Api.Password = credentials.Password
 	}
 
 	// Load shared configuration from the API.
@@ -137,22 +146,16 @@ func init() {
 
 			//region Version Selector Tool
 
-			// Load Unreal Engine source code Version Selector Tool path.
+			// Load Unreal Engine source code and marketplace Version Selector Tool paths.
 			config.Unreal.Code.VersionSelectorPath = os.Getenv("UNREAL_CODE_VERSION_SELECTOR_PATH")
-			if config.Unreal.Code.VersionSelectorPath == "" {
-				logger.Logger.Fatalln("required env UNREAL_CODE_VERSION_SELECTOR_PATH is not defined")
-			}
-
-			// Load Unreal Engine marketplace Version Selector Tool path.
 			config.Unreal.Marketplace.VersionSelectorPath = os.Getenv("UNREAL_MARKETPLACE_VERSION_SELECTOR_PATH")
-			if config.Unreal.Marketplace.VersionSelectorPath == "" {
-				logger.Logger.Fatalln("required env UNREAL_MARKETPLACE_VERSION_SELECTOR_PATH is not defined")
-			}
 
-			// At least one of the Version Selector Tool paths must be defined, required for all jobs related to Unreal Engine.
-			if config.Unreal.Code.VersionSelectorPath == "" && config.Unreal.Marketplace.VersionSelectorPath == "" {
+			// At least one of the Version Selector Tool paths must be defined on Windows, required for all jobs related to
+			// Unreal Engine. Other platforms switch the engine version using the engine registry file.
+			if runtime.GOOS == "windows" && config.Unreal.Code.VersionSelectorPath == "" && config.Unreal.Marketplace.VersionSelectorPath == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypePackage]] ||
-					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] {
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] ||
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeTest]] {
 					logger.Logger.Fatalln("required env UNREAL_CODE_VERSION_SELECTOR_PATH or UNREAL_MARKETPLACE_VERSION_SELECTOR_PATH is not defined")
 				}
 			}
@@ -163,9 +166,11 @@ func init() {
 
 			// Load Unreal Engine source code Editor path.
 			config.Unreal.Code.EditorPath = os.Getenv("UNREAL_CODE_EDITOR_PATH")
-			// Code editor path is required for the UGC Package and Client/Server Release jobs.
+			// Code editor path is required for the UGC Package, Validate, Client/Server Release and Test jobs.
 			if config.Unreal.Code.EditorPath == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypePackage]] ||
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeValidate]] ||
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeTest]] ||
 					(config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
 						!config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypeEditor]]) {
 					logger.Logger.Fatalln("required env UNREAL_CODE_EDITOR_PATH is not defined")
@@ -221,6 +226,101 @@ func init() {
 
 			//endregion
 
+			//region Unreal Engine installations
+
+			// Load installed engines, e.g. "5.1-source=/opt/UnrealEngine-5.1,/opt/UnrealEngine-5.2", the identifier is optional.
+			if v := os.Getenv("UNREAL_ENGINES"); v != "" {
+				for _, entry := range strings.Split(v, ",") {
+					id, rootDir, ok := strings.Cut(entry, "=")
+					if !ok {
+						id, rootDir = "", entry
+					}
+					config.Unreal.Engines = append(config.Unreal.Engines, config.EngineConfig{Id: id, RootDir: rootDir})
+				}
+			}
+
+			// Load directories scanned for installed engines, e.g. "/opt/engines,/mnt/engines".
+			if v := os.Getenv("UNREAL_ENGINE_DIRS"); v != "" {
+				config.Unreal.EngineDirs = strings.Split(v, ",")
+			}
+
+			// Load the engines, jobs select the engine the project is associated with, the source code engine is used if none.
+			engines, engineErr := unreal.LoadEngines(config.Unreal.Engines, config.Unreal.EngineDirs)
+			if engineErr != nil {
+				logger.Logger.Fatalf("failed to load engines: %v", engineErr)
+			}
+			for _, e := range engines {
+				logger.Logger.Infof("engine %s: version %s, source %t, %s", e.Id, e.Version, e.Source, e.RootDir)
+			}
+
+			//endregion
+
+			//region Unreal Engine command environment and limits
+
+			// Load additional environment variables for Unreal Engine commands, e.g. "LINUX_MULTIARCH_ROOT=/opt/toolchain,UE-SharedDataCachePath=/mnt/ddc".
+			if unrealEnv := os.Getenv("UNREAL_ENV"); unrealEnv != "" {
+				for _, kv := range strings.Split(unrealEnv, ",") {
+					key, value, ok := strings.Cut(kv, "=")
+					if !ok || key == "" {
+						logger.Logger.Fatalf("invalid env UNREAL_ENV entry %q, expected KEY=VALUE", kv)
+					}
+					config.Unreal.Environment[key] = value
+				}
+			}
+
+			// Load optional resource limits for Unreal Engine commands.
+			if v := os.Getenv("UNREAL_TIMEOUT"); v != "" {
+				timeout, err := time.ParseDuration(v)
+				if err != nil {
+					logger.Logger.Fatalf("invalid env UNREAL_TIMEOUT: %v", err)
+				}
+				config.Unreal.Limits.Timeout = timeout
+			}
+			if v := os.Getenv("UNREAL_NICE"); v != "" {
+				nice, err := strconv.Atoi(v)
+				if err != nil {
+					logger.Logger.Fatalf("invalid env UNREAL_NICE: %v", err)
+				}
+				config.Unreal.Limits.Nice = nice
+			}
+			if v := os.Getenv("UNREAL_MEMORY_LIMIT"); v != "" {
+				limit, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					logger.Logger.Fatalf("invalid env UNREAL_MEMORY_LIMIT: %v", err)
+				}
+				config.Unreal.Limits.MemoryLimit = limit
+			}
+
+			// Load optional inactivity timeout for Unreal Engine commands, hung commands are terminated after the timeout.
+			if v := os.Getenv("UNREAL_INACTIVITY_TIMEOUT"); v != "" {
+				timeout, err := time.ParseDuration(v)
+				if err != nil {
+					logger.Logger.Fatalf("invalid env UNREAL_INACTIVITY_TIMEOUT: %v", err)
+				}
+				config.Unreal.Limits.InactivityTimeout = timeout
+			}
+
+			// Load optional cgroup v2 directory used to enforce memory limits on Linux.
+			config.Config.CgroupRoot = os.Getenv("CGROUP_ROOT")
+
+			// Load the optional timestamp of the reproducible release archive entries, in seconds since the Unix epoch.
+			if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+				epoch, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || epoch < 0 {
+					logger.Logger.Fatalf("invalid env SOURCE_DATE_EPOCH: %s", v)
+				}
+				config.Config.SourceDateEpoch = time.Unix(epoch, 0).UTC()
+			}
+
+			//endregion
+
+			//region Source directories
+
+			// Load the source directories and their repositories, the same as the workspace commands.
+			loadSourceConfig()
+
+			//endregion
+
 			//region Client Launcher (Wails)
 
 			// Load Wails path.
@@ -233,9 +333,7 @@ func init() {
 				}
 			}
 
-			// Load launcher source code path.
-			config.ClientLauncher.SourceDir = os.Getenv("LAUNCHER_SOURCE_DIR")
-			// Required for the ClientLauncher job.
+			// Launcher source code path is required for the ClientLauncher job.
 			if config.ClientLauncher.SourceDir == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
 					config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypeLauncher]] {
@@ -247,9 +345,7 @@ func init() {
 
 			//region Server Launcher
 
-			// Load server launcher source code path.
-			config.ServerLauncher.SourceDir = os.Getenv("SERVER_LAUNCHER_SOURCE_DIR")
-			// Required for the ServerLauncher job.
+			// Server launcher source code path is required for the ServerLauncher job.
 			if config.ServerLauncher.SourceDir == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
 					config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypeServerLauncher]] {
@@ -261,9 +357,7 @@ func init() {
 
 			//region Pixel Streaming Launcher
 
-			// Load pixel streaming launcher source code path.
-			config.PixelStreamingLauncher.SourceDir = os.Getenv("PIXEL_STREAMING_LAUNCHER_SOURCE_DIR")
-			// Required for the PixelStreamingLauncher job.
+			// Pixel streaming launcher source code path is required for the PixelStreamingLauncher job.
 			if config.PixelStreamingLauncher.SourceDir == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
 					config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypePixelStreamingLauncher]] {
@@ -273,14 +367,43 @@ func init() {
 
 			//endregion
 
+			//region Source repositories
+
+			// The project directory and name are required for the UGC Package, Validate, Test and Client/Server Release jobs.
+			if config.Unreal.Project.Directory == "" || config.Unreal.Project.Name == "" {
+				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypePackage]] ||
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeValidate]] ||
+					config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeTest]] ||
+					(config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
+						(config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypeClient]] ||
+							config.Config.EnabledTargets[config.Config.TargetMapping[config.TargetTypeServer]])) {
+					logger.Logger.Fatalln("required envs PROJECT_DIR and PROJECT_NAME are not defined")
+				}
+			}
+
+			// Load the release tag signature verification, the SSH signatures are verified against the allowed signers file.
+			config.Git.VerifyTags = os.Getenv("GIT_VERIFY_TAGS") == "true"
+			config.Git.AllowedSignersFile = os.Getenv("GIT_ALLOWED_SIGNERS_FILE")
+
+			// Bootstrap the missing source directories, so new build nodes need no manual clone steps.
+			if err := processing.EnsureRepos(ctx); err != nil {
+				logger.Logger.Fatalf("failed to bootstrap source repositories: %v", err)
+			}
+
+			//endregion
+
 			//region Code Signing
 
 			// Load code signing tool path.
 			config.CodeSigning.ToolPath = os.Getenv("CODE_SIGNING_TOOL_PATH")
 			// Load code signing tool certificate path.
 			config.CodeSigning.CertificatePath = os.Getenv("CODE_SIGNING_CERTIFICATE_PATH")
-			// Load code signing tool certificate password.
-			config.CodeSigning.CertificatePassword = os.Getenv("CODE_SIGNING_CERTIFICATE_PASSWORD")
+			// Load code signing tool certificate password, can be read from the file at CODE_SIGNING_CERTIFICATE_PASSWORD_FILE.
+			var err error
+			config.CodeSigning.CertificatePassword, err = secrets.Getenv("CODE_SIGNING_CERTIFICATE_PASSWORD")
+			if err != nil {
+				logger.Logger.Fatalln(err)
+			}
 			// Optional for the Client and Launcher Release job on Win64 platform.
 			if config.CodeSigning.ToolPath == "" || config.CodeSigning.CertificatePath == "" || config.CodeSigning.CertificatePassword == "" {
 				if config.Config.EnabledJobs[config.Config.JobMapping[config.JobTypeRelease]] &&
@@ -293,13 +416,49 @@ func init() {
 
 			//endregion
 
+			//region Shared configuration reload
+
+			// Load the shared configuration reload interval.
+			if v := os.Getenv("SHARED_CONFIG_RELOAD_INTERVAL"); v != "" {
+				interval, err := time.ParseDuration(v)
+				if err != nil {
+					logger.Logger.Fatalf("invalid env SHARED_CONFIG_RELOAD_INTERVAL: %v", err)
+				}
+				config.Config.SharedConfigReloadInterval = interval
+			}
+
+			// Request the shared configuration reload on SIGHUP, the reload is performed before the next job.
+			var reloadRequested atomic.Bool
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			go func() {
+				for range hup {
+					logger.Logger.Infof("received SIGHUP, shared configuration will be reloaded before the next job")
+					reloadRequested.Store(true)
+				}
+			}()
+
+			//endregion
+
+			lastReload := time.Now()
 			for {
+				// Reload the shared configuration between jobs, so a job never sees a partially updated configuration.
+				if reloadRequested.Swap(false) || time.Since(lastReload) >= config.Config.SharedConfigReloadInterval {
+					if err := api.LoadSharedConfiguration(ctx); err != nil {
+						logger.Logger.Errorf("failed to reload shared configuration: %v, continuing with current values", err)
+					}
+					lastReload = time.Now()
+				}
+
 				if err := processing.Process(ctx); err != nil {
 					logger.Logger.Errorf("failed to process a job: %v", err)
 				}
 			}
 		},
 	}
+
+	// Add the workspace subcommands, e.g. to fix the checkout of a node without running jobs.
+	rootCmd.AddCommand(newWorkspaceCmd())
 }
 
 func main() {
@@ -308,3 +467,39 @@ func main() {
 		logger.Logger.Fatalln(err)
 	}
 }
+
+// loadSourceConfig loads the project and the launcher source directories and their optional source repositories, the
+// missing source directories are cloned. Used by the job processing and the workspace commands.
+func loadSourceConfig() {
+	config.Unreal.Project.Directory = os.Getenv("PROJECT_DIR")
+	config.Unreal.Project.Name = os.Getenv("PROJECT_NAME")
+	config.ClientLauncher.SourceDir = os.Getenv("LAUNCHER_SOURCE_DIR")
+	config.ServerLauncher.SourceDir = os.Getenv("SERVER_LAUNCHER_SOURCE_DIR")
+	config.PixelStreamingLauncher.SourceDir = os.Getenv("PIXEL_STREAMING_LAUNCHER_SOURCE_DIR")
+
+	config.Unreal.Project.Repo = loadRepoConfig("PROJECT")
+	config.ClientLauncher.Repo = loadRepoConfig("LAUNCHER")
+	config.ServerLauncher.Repo = loadRepoConfig("SERVER_LAUNCHER")
+	config.PixelStreamingLauncher.Repo = loadRepoConfig("PIXEL_STREAMING_LAUNCHER")
+}
+
+// loadRepoConfig loads the source repository configuration from the envs with the prefix, e.g. PROJECT_REPO_URL.
+func loadRepoConfig(prefix string) config.RepoConfig {
+	repo := config.RepoConfig{
+		Url:       os.Getenv(prefix + "_REPO_URL"),
+		Branch:    os.Getenv(prefix + "_REPO_BRANCH"),
+		Filter:    os.Getenv(prefix + "_REPO_FILTER"),
+		Reference: os.Getenv(prefix + "_REPO_REFERENCE"),
+		Worktree:  os.Getenv(prefix + "_REPO_WORKTREE_OF"),
+	}
+
+	if v := os.Getenv(prefix + "_REPO_DEPTH"); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 0 {
+			logger.Logger.Fatalf("invalid env %s_REPO_DEPTH: %s", prefix, v)
+		}
+		repo.Depth = depth
+	}
+
+	return repo
+}
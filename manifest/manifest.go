@@ -0,0 +1,45 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"l7-cloud-builder/archive"
+	"l7-cloud-builder/git"
+	"l7-cloud-builder/unreal"
+	"os"
+	"time"
+)
+
+// Release is the release manifest uploaded with the release files, records how the release was built
+type Release struct {
+	JobId         string                  `json:"jobId"`
+	ReleaseId     string                  `json:"releaseId"`
+	Version       string                  `json:"version"`     // Release version
+	CodeVersion   string                  `json:"codeVersion"` // Source code version the release was built from
+	Target        string                  `json:"target"`
+	Platform      string                  `json:"platform"`
+	Configuration string                  `json:"configuration"`
+	Workspace     *git.Workspace          `json:"workspace,omitempty"`    // Workspace hygiene report and the checked out commit
+	Provenance    *git.Provenance         `json:"provenance,omitempty"`   // Source commit, tag, signature, submodules and LFS objects the release was built from
+	Engine        *unreal.Engine          `json:"engine,omitempty"`       // Engine the release was built with
+	Project       *unreal.ProjectCheck    `json:"project,omitempty"`      // Project descriptor check result
+	Maps          *unreal.CookMaps        `json:"maps,omitempty"`         // Maps cooked for the release
+	IniOverrides  []unreal.IniOverride    `json:"iniOverrides,omitempty"` // Project config values overridden for the build
+	SmokeTest     *unreal.SmokeTestResult `json:"smokeTest,omitempty"`    // Dedicated server smoke test result
+	Archive       *archive.ZipInfo        `json:"archive,omitempty"`      // Release archive size and SHA-256
+	BuiltAt       time.Time               `json:"builtAt"`
+}
+
+// Write writes the manifest to the file as indented JSON.
+func (r *Release) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode release manifest: %w", err)
+	}
+
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write release manifest: %w", err)
+	}
+
+	return nil
+}
@@ -11,16 +11,23 @@ import (
 	"fmt"
 	"l7-cloud-builder/api"
 	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
 	"l7-cloud-builder/logger"
 	"time"
 )
 
+// jobStatusTimeout is the timeout of the final job status update
+const jobStatusTimeout = time.Minute
+
 func Process(ctx context.Context) (err error) {
+	// Message reported in the job status when the job is completed, e.g. the test pass/fail counts or the release source
+	var message string
 
 	//region Wait for an unclaimed job
 
 	var job *sm.JobV2
-	job, err = api.FetchUnclaimedJob(ctx)
+	var releaseEngine string
+	job, releaseEngine, err = api.FetchUnclaimedJob(ctx)
 	if err != nil {
 		return err
 	}
@@ -38,12 +45,22 @@ func Process(ctx context.Context) (err error) {
 
 	defer func(job *sm.JobV2) {
 		if job != nil {
+			// The job context is cancelled when the job is cancelled, the final status is reported with a separate context
+			statusCtx, cancel := context.WithTimeout(context.Background(), jobStatusTimeout)
+			defer cancel()
+
 			if err != nil {
-				if err1 := api.UpdateJobStatus(ctx, job, config.JobStatusError, err.Error()); err1 != nil {
+				// Report the failure category, so the build node failures can be told apart from the code or content failures
+				category := failure.Classify(err)
+				status := config.JobStatusType(config.JobStatusError)
+				if category == config.FailureCategoryCancelled {
+					status = config.JobStatusCancelled
+				}
+				if err1 := api.UpdateJobFailure(statusCtx, job, status, err.Error(), category); err1 != nil {
 					logger.Logger.Errorf("failed to update job status: %v", err1)
 				}
 			} else {
-				if err1 := api.UpdateJobStatus(ctx, job, config.JobStatusCompleted, ""); err1 != nil {
+				if err1 := api.UpdateJobStatus(statusCtx, job, config.JobStatusCompleted, message); err1 != nil {
 					logger.Logger.Errorf("failed to update job status: %v", err1)
 				}
 			}
@@ -85,9 +102,9 @@ func Process(ctx context.Context) (err error) {
 		}
 
 		if job.Target == config.Config.TargetMapping[config.TargetTypeClient] {
-			err = processReleaseClient(*job)
+			message, err = processReleaseClient(ctx, job, releaseEngine)
 		} else if job.Target == config.Config.TargetMapping[config.TargetTypeServer] {
-			err = processReleaseServer(*job)
+			message, err = processReleaseServer(ctx, job, releaseEngine)
 		} else if job.Target == config.Config.TargetMapping[config.TargetTypeEditor] {
 			err = processReleaseEditor(*job)
 		} else if job.Target == config.Config.TargetMapping[config.TargetTypeLauncher] {
@@ -112,6 +129,16 @@ func Process(ctx context.Context) (err error) {
 		} else {
 			err = fmt.Errorf("invalid job deployment %s for type %s", job.Target, job.Type)
 		}
+	} else if job.Type == config.Config.JobMapping[config.JobTypeTest] {
+		// The tests run at the release code version, or at the branch mapped to the job configuration without a release
+		message, err = processTest(ctx, job, releaseEngine)
+	} else if job.Type == config.Config.JobMapping[config.JobTypeValidate] {
+		// Requires the package to be set for the job
+		if job.Package == nil {
+			return fmt.Errorf("no package metadata, required for job type: %s", job.Type)
+		}
+
+		message, err = processValidate(ctx, job)
 	} else {
 		err = fmt.Errorf("invalid job type: %s", job.Type)
 	}
@@ -0,0 +1,412 @@
+package processing
+
+import (
+	"context"
+	sh "dev.hackerman.me/artheon/veverse-shared/helper"
+	sm "dev.hackerman.me/artheon/veverse-shared/model"
+	"fmt"
+	"l7-cloud-builder/api"
+	"l7-cloud-builder/archive"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
+	"l7-cloud-builder/git"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/manifest"
+	"l7-cloud-builder/unreal"
+	"l7-cloud-builder/upload"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// releaseBuild contains the state of a release job shared by the release processing steps
+type releaseBuild struct {
+	job              *sm.JobV2
+	shared           *config.SharedConfig // Shared configuration used for the whole job
+	workspace        *git.Workspace       // Workspace hygiene report and the checked out commit
+	resolvedRef      *git.ResolvedRef     // Code version checked out
+	provenance       *git.Provenance      // Source the release is built from
+	engine           *unreal.Engine       // Engine the project is associated with
+	projectCheck     *unreal.ProjectCheck // Project descriptor check result
+	cookMaps         *unreal.CookMaps     // Maps to cook
+	iniOverrides     []unreal.IniOverride // Project config values overridden for the build
+	stagingDirectory string               // Directory the release is staged to
+	smokeTest        *unreal.SmokeTestResult
+	archive          *archive.ZipInfo // Release archive, nil if the release is not archived
+}
+
+func generateReleaseBuildCookRun(job *sm.JobV2, shared *config.SharedConfig, engine *unreal.Engine, maps *unreal.CookMaps, target config.TargetType) (*unreal.BuildCookRun, error) {
+	// Validate job
+	if job == nil {
+		return nil, fmt.Errorf("job is nil")
+	}
+
+	// Validate job release
+	if job.Release == nil {
+		return nil, fmt.Errorf("job release is nil")
+	}
+
+	// Validate job release id
+	if job.Release.Id.IsNil() {
+		return nil, fmt.Errorf("invalid job release")
+	}
+
+	// Build, cook, pak, stage and package the target, the shipping configuration is built for distribution
+	b := unreal.NewBuildCookRun(config.Unreal.Project.Name+".uproject", target, job.Platform, job.Configuration)
+	b.UnrealExe = engine.EditorPath
+	b.CreateReleaseVersion = job.Release.Version
+	b.StagingDirectory = filepath.Join(unreal.GetStagingDir(config.Unreal.Project.Directory), job.Release.Version)
+	b.Ini = []unreal.IniOverride{
+		{File: "Game", Section: "/Script/UnrealEd.ProjectPackagingSettings", Key: "BlueprintNativizationMethod", Value: "Disabled"},
+	}
+
+	// Maps to cook, the packaging settings apply if the release does not list the maps
+	if maps != nil && maps.Source == unreal.MapsSourceRelease {
+		b.Maps = maps.Maps
+	}
+
+	// Stage the debug files of every release for the crash reports
+	b.DebugInfo = true
+	b.Debug = true
+
+	// Add extra arguments from the shared configuration matching the job
+	b.ExtraArgs = shared.BuildCookRunArgs(job.Target, job.Platform, job.Configuration)
+
+	return b, b.Validate()
+}
+
+// releaseIniOverrides returns the project config values overridden for the release build: the project version, the build
+// id and the shared configuration overrides of the API environment, e.g. the API URL.
+func releaseIniOverrides(job *sm.JobV2, shared *config.SharedConfig) []unreal.IniOverride {
+	overrides := []unreal.IniOverride{
+		{File: "Game", Section: "/Script/EngineSettings.GeneralProjectSettings", Key: "ProjectVersion", Value: job.Release.Version},
+		{File: "Game", Section: "BuildInfo", Key: "BuildId", Value: job.Id.String()},
+	}
+
+	// Replace the placeholders in the shared configuration values
+	replacer := strings.NewReplacer("{apiUrl}", config.Api.Url, "{releaseVersion}", job.Release.Version, "{jobId}", job.Id.String())
+	for _, o := range shared.IniOverrides(job.Target, job.Platform, job.Configuration) {
+		overrides = append(overrides, unreal.IniOverride{File: o.File, Section: o.Section, Key: o.Key, Value: replacer.Replace(o.Value)})
+	}
+
+	return overrides
+}
+
+// codeRef returns the code version the job builds: the release code version tag, branch or commit, or the branch mapped
+// to the job configuration if the job has no code version, e.g. the development branch HEAD for the nightly test jobs.
+func codeRef(job *sm.JobV2) (string, error) {
+	if job.Release != nil && job.Release.CodeVersion != "" {
+		return job.Release.CodeVersion, nil
+	}
+
+	if branch := config.Git.BranchMapping[job.Configuration]; branch != "" {
+		return branch, nil
+	}
+
+	return "", fmt.Errorf("invalid job: no code version and no branch mapped to configuration %s", job.Configuration)
+}
+
+// prepareRelease validates the release job, checks out the release code version, selects the engine required by the
+// release, checks the project descriptor and resolves the maps, so the invalid jobs fail before the build.
+func prepareRelease(ctx context.Context, job *sm.JobV2, releaseEngine string) (r *releaseBuild, err error) {
+	if job == nil {
+		return nil, fmt.Errorf("job is nil")
+	}
+
+	// Mark the job as processing
+	if err = api.UpdateJobStatus(ctx, job, config.JobStatusProcessing, ""); err != nil {
+		return nil, err
+	}
+
+	// Use the same shared configuration for the whole job
+	r = &releaseBuild{job: job, shared: config.SharedConfiguration()}
+
+	//region Validate the received job
+
+	// Validate job type
+	if !config.Config.EnabledJobs[job.Type] {
+		return nil, fmt.Errorf("invalid job type: %s", job.Type)
+	}
+
+	// Validate job target
+	if !config.Config.EnabledTargets[job.Target] {
+		return nil, fmt.Errorf("invalid job target: %s", job.Target)
+	}
+
+	// Validate job platform
+	if !config.Config.EnabledPlatforms[job.Platform] {
+		return nil, fmt.Errorf("invalid job platform: %s", job.Platform)
+	}
+
+	// Validate job release, the test jobs without a release build the branch mapped to the job configuration
+	isRelease := job.Type == config.Config.JobMapping[config.JobTypeRelease]
+	if isRelease && job.Release == nil {
+		return nil, fmt.Errorf("job release is nil")
+	}
+
+	// Validate job release id
+	if isRelease && job.Release.Id.IsNil() {
+		return nil, fmt.Errorf("invalid job release")
+	}
+
+	// Get the code version to build
+	ref, err := codeRef(job)
+	if err != nil {
+		return nil, err
+	}
+
+	//endregion
+
+	// Clone the project if the directory is missing, retried on transient failures
+	if err = ensureRepo(ctx, r.shared, config.Unreal.Project.Directory, config.Unreal.Project.Repo); err != nil {
+		return nil, err
+	}
+
+	// Clean the workspace, sync the repo to the code version with the submodules and the LFS objects of the target,
+	// retried on transient failures
+	project := WorkspaceSource{Name: "project", Dir: config.Unreal.Project.Directory, Repo: config.Unreal.Project.Repo, Project: true}
+	r.workspace, r.resolvedRef, err = syncWorkspace(ctx, r.shared, project, ref, job.Target, job.Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the source the release is built from, the signature of the release tags is verified if required
+	r.provenance, err = git.ReadProvenance(ctx, config.Unreal.Project.Directory, r.resolvedRef.Ref, git.ProvenanceOptions{
+		Lfs:            r.shared.Workspace.Lfs,
+		VerifyTag:      config.Git.VerifyTags && isRelease,
+		AllowedSigners: config.Git.AllowedSignersFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.workspace.DirtyCount > 0 {
+		logger.Logger.Warningf("workspace %s was dirty, reset %d modified or untracked files: %s", config.Unreal.Project.Directory, r.workspace.DirtyCount, strings.Join(r.workspace.Dirty, ", "))
+	}
+	logger.Logger.Infof("workspace %s cleaned keeping %s, checked out %s %s at %s", config.Unreal.Project.Directory, strings.Join(r.workspace.Kept, ", "), r.workspace.RefKind, r.workspace.Ref, r.workspace.Commit)
+	logger.Logger.Infof("release %s, %d submodules, %d/%d LFS objects fetched", r.provenance, len(r.provenance.Submodules), r.provenance.LfsObjectsFetched, r.provenance.LfsObjects)
+
+	// Select the installed engine required by the release, else the one the project is associated with at the code version
+	r.engine, err = unreal.ProjectEngine(config.Unreal.Project.Directory, config.Unreal.Project.Name, releaseEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the project descriptor against the job before the build, so a broken descriptor fails early
+	engineDir := ""
+	if r.engine.RootDir != "" {
+		engineDir = filepath.Join(r.engine.RootDir, "Engine")
+	}
+	r.projectCheck, err = unreal.CheckProject(config.Unreal.Project.Directory, config.Unreal.Project.Name, job.Platform, engineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Switch the project engine version to the selected engine
+	r.projectCheck.EngineAssociation, err = unreal.SwitchProjectEngineVersion(ctx, config.Unreal.Project.Directory, config.Unreal.Project.Name, r.engine.Association)
+	if err != nil {
+		return nil, fmt.Errorf("failed to switch project engine version: %w", err)
+	}
+
+	logger.Logger.Infof("project %s checked for %s: engine %s (%s), %d modules, %d plugins", r.projectCheck.Path, r.projectCheck.Platform, r.engine.Id, r.engine.Version, len(r.projectCheck.Modules), len(r.projectCheck.Plugins))
+
+	// The jobs without a release do not cook nor stage
+	if job.Release == nil {
+		return r, nil
+	}
+
+	// Resolve the maps to cook, missing maps fail the job before the build
+	r.cookMaps, err = unreal.ResolveMaps(config.Unreal.Project.Directory, job.Release.Options.Maps, r.shared.Maps)
+	if err != nil {
+		return nil, err
+	}
+
+	r.stagingDirectory = filepath.Join(unreal.GetStagingDir(config.Unreal.Project.Directory), job.Release.Version)
+
+	return r, nil
+}
+
+// build builds, cooks and stages the release target with the project config overrides applied.
+func (r *releaseBuild) build(ctx context.Context, target config.TargetType) (err error) {
+	// Generate the command line arguments
+	buildCookRun, err := generateReleaseBuildCookRun(r.job, r.shared, r.engine, r.cookMaps, target)
+	if err != nil {
+		return err
+	}
+
+	// Apply the project config overrides of the environment, the files are restored after the build to keep the worktree clean
+	r.iniOverrides = releaseIniOverrides(r.job, r.shared)
+	restoreIni, err := unreal.ApplyIniOverrides(config.Unreal.Project.Directory, r.iniOverrides)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err1 := restoreIni(); err1 != nil {
+			logger.Logger.Errorf("failed to restore project config: %v", err1)
+		}
+	}()
+
+	// Run the selected engine Unreal Automation Tool to build the target, retried on transient failures
+	return failure.Retry(ctx, r.shared, failure.PhaseBuild, func() error {
+		return buildCookRun.Run(ctx, config.Unreal.Project.Directory, r.engine.AutomationToolPath)
+	})
+}
+
+// archiveModTime returns the timestamp of the reproducible archive entries: SOURCE_DATE_EPOCH, else the release commit
+// date, so the nodes building the same commit produce the same archive.
+func (r *releaseBuild) archiveModTime() time.Time {
+	if !config.Config.SourceDateEpoch.IsZero() {
+		return config.Config.SourceDateEpoch
+	}
+
+	if r.provenance != nil {
+		if commitDate, err := time.Parse(time.RFC3339, r.provenance.CommitDate); err == nil {
+			return commitDate
+		}
+	}
+
+	return time.Time{}
+}
+
+// uploadChangelog generates the changelog since the previous release tag and uploads it as markdown and JSON. The
+// changelog is informational, a failure to generate, write or upload it is logged and does not fail the release, the
+// archive and the manifest are already uploaded.
+func (r *releaseBuild) uploadChangelog(ctx context.Context) {
+	job := r.job
+
+	if !r.shared.Changelog.Enabled {
+		return
+	}
+
+	changelog, err := git.GenerateChangelog(ctx, config.Unreal.Project.Directory, r.resolvedRef.Ref, git.ChangelogOptions{
+		TagPattern: r.shared.Changelog.TagPattern,
+		IssueUrl:   r.shared.Changelog.IssueUrl,
+		MaxCommits: r.shared.Changelog.MaxCommits,
+	})
+	if err != nil {
+		logger.Logger.Warningf("failed to generate a release changelog: %s", err)
+		return
+	}
+
+	// The launcher shows what's new since the previous release tag, or the whole history for the first release
+	changelog.To = r.resolvedRef.Name
+	logger.Logger.Infof("release changelog since %q: %d commits", changelog.From, changelog.Commits)
+
+	changelogJson, err := changelog.JSON()
+	if err != nil {
+		logger.Logger.Warningf("failed to encode a release changelog: %s", err)
+		return
+	}
+
+	baseName := fmt.Sprintf("%s-%s-%s-%s-%s.changelog", job.Release.App.Id.String(), job.Release.Version, job.Target, job.Platform, job.Configuration)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{baseName + ".md", []byte(changelog.Markdown())},
+		{baseName + ".json", changelogJson},
+	}
+
+	for _, file := range files {
+		if err = os.WriteFile(file.name, file.data, 0644); err != nil {
+			logger.Logger.Warningf("failed to write a release changelog: %s", err)
+			return
+		}
+
+		// Upload the changelog, retried on transient failures
+		fileName := file.name
+		err = failure.Retry(ctx, r.shared, failure.PhaseUpload, func() error {
+			return upload.ReleaseChangelog(ctx, job.Release.Id, job.Target, job.Platform, fileName, fileName, nil)
+		})
+		if err != nil {
+			logger.Logger.Warningf("failed to upload a release changelog: %s", err)
+			return
+		}
+	}
+}
+
+// upload uploads the staged release, the release manifest and the changelog.
+func (r *releaseBuild) upload(ctx context.Context) (err error) {
+	job := r.job
+
+	// Get list of ignored files from the config
+	ignoredFiles := r.shared.Release.IgnoredFiles
+
+	// Get list of files in the staging directory
+	files, err := sh.ListFilesRecursive(r.stagingDirectory, ignoredFiles)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	// Check if release is a archive
+	if job.Release.Options.Archive {
+		zipFileName := fmt.Sprintf("%s-%s-%s-%s-%s.zip", job.Release.App.Id.String(), job.Release.Version, job.Target, job.Platform, job.Configuration) // e.g.
+
+		// Create the archive, the reproducible archives are timestamped with SOURCE_DATE_EPOCH or the commit date, the
+		// Linux binaries and scripts are executable after the extraction
+		zipOptions := archive.ZipOptions{
+			Reproducible:     r.shared.Release.Archive.Reproducible,
+			ModTime:          r.archiveModTime(),
+			CompressionLevel: r.shared.Release.Archive.CompressionLevel,
+			Executables:      job.Platform == config.Config.PlatformMapping[config.PlatformTypeLinux],
+		}
+		r.archive, err = archive.CreateZipArchive(zipFileName, r.stagingDirectory, files, zipOptions)
+		if err != nil {
+			return fmt.Errorf("failed to create a release archive: %w", err)
+		}
+
+		// Verify the entries, the modes and the symlinks before the upload
+		if err = archive.VerifyZipArchive(zipFileName, r.stagingDirectory, files, zipOptions); err != nil {
+			return err
+		}
+		logger.Logger.Infof("release archive %s: %d files, %d bytes, sha256 %s", r.archive.Name, r.archive.Files, r.archive.Size, r.archive.Sha256)
+
+		// Upload the archive, retried on transient failures
+		err = failure.Retry(ctx, r.shared, failure.PhaseUpload, func() error {
+			return upload.ReleaseArchive(ctx, job.Release.Id, job.Target, job.Platform, zipFileName, zipFileName, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload a release archive: %w", err)
+		}
+	} else {
+		// Upload the files one by one
+	}
+
+	// Record how the release was built
+	releaseManifest := &manifest.Release{
+		JobId:         job.Id.String(),
+		ReleaseId:     job.Release.Id.String(),
+		Version:       job.Release.Version,
+		CodeVersion:   job.Release.CodeVersion,
+		Target:        job.Target,
+		Platform:      job.Platform,
+		Configuration: job.Configuration,
+		Workspace:     r.workspace,
+		Provenance:    r.provenance,
+		Engine:        r.engine,
+		Project:       r.projectCheck,
+		Maps:          r.cookMaps,
+		IniOverrides:  r.iniOverrides,
+		SmokeTest:     r.smokeTest,
+		Archive:       r.archive,
+		BuiltAt:       time.Now().UTC(),
+	}
+
+	manifestFileName := fmt.Sprintf("%s-%s-%s-%s-%s.manifest.json", job.Release.App.Id.String(), job.Release.Version, job.Target, job.Platform, job.Configuration)
+	if err = releaseManifest.Write(manifestFileName); err != nil {
+		return err
+	}
+
+	// Upload the manifest, retried on transient failures
+	err = failure.Retry(ctx, r.shared, failure.PhaseUpload, func() error {
+		return upload.ReleaseManifest(ctx, job.Release.Id, job.Target, job.Platform, manifestFileName, manifestFileName, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload a release manifest: %w", err)
+	}
+
+	r.uploadChangelog(ctx)
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package processing
+
+import (
+	"context"
+	sm "dev.hackerman.me/artheon/veverse-shared/model"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/unreal"
+	"runtime"
+	"time"
+)
+
+func processReleaseServer(ctx context.Context, job *sm.JobV2, releaseEngine string) (message string, err error) {
+	// Validate the job, checkout the release code version and check the project
+	r, err := prepareRelease(ctx, job, releaseEngine)
+	if err != nil {
+		return "", err
+	}
+
+	// Build, cook and stage the dedicated server
+	if err = r.build(ctx, config.TargetTypeServer); err != nil {
+		return "", err
+	}
+
+	// Smoke test the staged server, a server failing to start fails the job before the upload
+	if err = r.smokeTestServer(ctx); err != nil {
+		return "", err
+	}
+
+	// Upload the staged server and the release manifest
+	if err = r.upload(ctx); err != nil {
+		return "", err
+	}
+
+	// Report the source the release was built from with the job result
+	return r.provenance.String(), nil
+}
+
+// smokeTestServer launches the staged dedicated server and waits for it to get ready if enabled in the shared
+// configuration. Only the Linux servers built on Linux nodes are tested.
+func (r *releaseBuild) smokeTestServer(ctx context.Context) error {
+	smokeTest := r.shared.SmokeTest
+	if !smokeTest.Enabled {
+		return nil
+	}
+
+	if r.job.Platform != config.Config.PlatformMapping[config.PlatformTypeLinux] || runtime.GOOS != "linux" {
+		logger.Logger.Infof("skipping server smoke test for platform %s on %s", r.job.Platform, runtime.GOOS)
+		return nil
+	}
+
+	server, err := unreal.FindServerBinary(r.stagingDirectory, config.Unreal.Project.Name)
+	if err != nil {
+		return err
+	}
+
+	r.smokeTest, err = unreal.SmokeTestServer(ctx, server, smokeTest.ReadyPattern, time.Duration(smokeTest.TimeoutSeconds)*time.Second, smokeTest.Args)
+	if err != nil {
+		return err
+	}
+
+	logger.Logger.Infof("server smoke test passed in %s: %s", r.smokeTest.Duration, r.smokeTest.ReadyLine)
+
+	return nil
+}
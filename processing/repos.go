@@ -0,0 +1,52 @@
+package processing
+
+import (
+	"context"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
+	"l7-cloud-builder/git"
+	"l7-cloud-builder/logger"
+)
+
+// ensureRepo clones the source repository into the directory if it is missing, retried on transient failures.
+func ensureRepo(ctx context.Context, shared *config.SharedConfig, dir string, repo config.RepoConfig) error {
+	return failure.Retry(ctx, shared, failure.PhaseSync, func() error {
+		created, err := git.EnsureRepo(ctx, dir, git.RepoOptions{
+			Url:       repo.Url,
+			Branch:    repo.Branch,
+			Filter:    repo.Filter,
+			Depth:     repo.Depth,
+			Reference: repo.Reference,
+			Worktree:  repo.Worktree,
+		})
+		if err != nil {
+			return err
+		}
+
+		if created && repo.Worktree != "" {
+			logger.Logger.Infof("bootstrapped repository %s as a worktree of %s", dir, repo.Worktree)
+		} else if created {
+			logger.Logger.Infof("bootstrapped repository %s", dir)
+		}
+
+		return nil
+	})
+}
+
+// EnsureRepos clones the missing source directories having a configured remote, so new build nodes need no manual
+// clone steps.
+func EnsureRepos(ctx context.Context) error {
+	shared := config.SharedConfiguration()
+
+	for _, s := range WorkspaceSources() {
+		if s.Repo.Url == "" && s.Repo.Worktree == "" {
+			continue
+		}
+
+		if err := ensureRepo(ctx, shared, s.Dir, s.Repo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package processing
+
+import (
+	"context"
+	sm "dev.hackerman.me/artheon/veverse-shared/model"
+	"fmt"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/unreal"
+	"l7-cloud-builder/upload"
+	"path/filepath"
+)
+
+// processTest runs the project automation tests at the job code version and uploads the test report as a job artifact.
+// Returns the pass/fail counts reported in the job status.
+func processTest(ctx context.Context, job *sm.JobV2, releaseEngine string) (message string, err error) {
+	// Validate the job, checkout the code version and check the project
+	r, err := prepareRelease(ctx, job, releaseEngine)
+	if err != nil {
+		return "", err
+	}
+
+	// Run the automation tests with the selected engine Editor, the report is written to the project saved directory
+	reportDir := filepath.Join(config.Unreal.Project.Directory, "Saved", "Automation", "Reports", job.Id.String())
+	report, testErr := unreal.RunAutomationTests(ctx, r.engine.EditorPath, config.Unreal.Project.Directory, config.Unreal.Project.Name, r.shared.Test.Filter, reportDir)
+	if report == nil {
+		return "", testErr
+	}
+
+	logger.Logger.Infof("automation tests %s finished in %.0fs, %s", r.shared.Test.Filter, report.Duration, report.Message())
+
+	// Upload the report even if the tests failed, retried on transient failures
+	err = failure.Retry(ctx, r.shared, failure.PhaseUpload, func() error {
+		return upload.TestReport(ctx, job.Id, job.Target, job.Platform, report.Path, filepath.Base(report.Path), nil)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload a test report: %w", err)
+	}
+
+	// Fail the job if any test failed, the error contains the pass/fail counts
+	if testErr != nil {
+		return "", testErr
+	}
+
+	return report.Message(), nil
+}
@@ -0,0 +1,131 @@
+package processing
+
+import (
+	"context"
+	sm "dev.hackerman.me/artheon/veverse-shared/model"
+	"fmt"
+	"l7-cloud-builder/api"
+	"l7-cloud-builder/archive"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/unreal"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractPackageContent downloads the package source archive and extracts it to the package plugin directory of the
+// project, returns a function removing the plugin directory after the validation. The workspace clean removes the
+// untracked plugins, so the package content is extracted for every validation.
+func extractPackageContent(ctx context.Context, shared *config.SharedConfig, job *sm.JobV2) (func(), error) {
+	// The package name is the plugin directory name
+	name := job.Package.Name
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid package name %q", name)
+	}
+
+	// Do not overwrite the plugins of the project
+	pluginDir := filepath.Join(config.Unreal.Project.Directory, "Plugins", name)
+	if _, err := os.Stat(pluginDir); err == nil {
+		return nil, fmt.Errorf("invalid package %s: plugin directory %s already exists in the project", name, pluginDir)
+	}
+
+	// Download the source archive, retried on transient failures
+	archivePath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s.zip", job.Package.Id, job.Id))
+	defer func() {
+		if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+			logger.Logger.Warningf("failed to remove package source archive %s: %v", archivePath, err)
+		}
+	}()
+
+	err := failure.Retry(ctx, shared, failure.PhaseSync, func() error {
+		return api.DownloadPackageSource(ctx, job.Package.Id, archivePath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download package %s source: %w", name, err)
+	}
+
+	cleanup := func() {
+		if err := os.RemoveAll(pluginDir); err != nil {
+			logger.Logger.Warningf("failed to remove package plugin directory %s: %v", pluginDir, err)
+		}
+	}
+
+	// Extract the content plugin to the project
+	if err = archive.ExtractZipArchive(archivePath, pluginDir); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("invalid package %s: %w", name, err)
+	}
+
+	return cleanup, nil
+}
+
+// processValidate runs the data validation on the UGC package content and posts the findings to the package, so the
+// content errors are reported before the package is cooked. Returns the error and warning counts reported in the job
+// status.
+func processValidate(ctx context.Context, job *sm.JobV2) (message string, err error) {
+	if job == nil {
+		return "", fmt.Errorf("job is nil")
+	}
+
+	// Mark the job as processing
+	if err = api.UpdateJobStatus(ctx, job, config.JobStatusProcessing, ""); err != nil {
+		return "", err
+	}
+
+	// Use the same shared configuration for the whole job
+	shared := config.SharedConfiguration()
+
+	// Validate job package
+	if job.Package == nil {
+		return "", fmt.Errorf("job package is nil")
+	}
+
+	// Validate job package id
+	if job.Package.Id.IsNil() {
+		return "", fmt.Errorf("invalid job package")
+	}
+
+	// The package content is a content plugin extracted to the project, validated against the current project checkout and
+	// removed after the validation
+	cleanup, err := extractPackageContent(ctx, shared, job)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err = unreal.CheckPackageContent(config.Unreal.Project.Directory, job.Package.Name); err != nil {
+		return "", err
+	}
+
+	// Select the installed engine the project is associated with, the packages have no release metadata
+	engine, err := unreal.ProjectEngine(config.Unreal.Project.Directory, config.Unreal.Project.Name, "")
+	if err != nil {
+		return "", err
+	}
+
+	// Run the validation commandlet and collect the findings of the package content
+	mount := "/" + job.Package.Name + "/"
+	result, validationErr := unreal.RunContentValidation(ctx, engine.EditorPath, config.Unreal.Project.Directory, config.Unreal.Project.Name, shared.Validation.Commandlet, mount, shared.Validation.Args)
+	if result == nil {
+		return "", validationErr
+	}
+
+	logger.Logger.Infof("package %s content validated, %s", job.Package.Name, result.Message())
+
+	// Post the findings to the package even if the validation failed, retried on transient failures
+	err = failure.Retry(ctx, shared, failure.PhaseUpload, func() error {
+		return api.UpdatePackageValidation(ctx, job.Package.Id, job.Id, result)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update package validation: %w", err)
+	}
+
+	// Fail the job if any errors are found, the error contains the first findings
+	if validationErr != nil {
+		return "", validationErr
+	}
+
+	return result.Message(), nil
+}
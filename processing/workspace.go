@@ -0,0 +1,118 @@
+package processing
+
+import (
+	"context"
+	"fmt"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/failure"
+	"l7-cloud-builder/git"
+	"strings"
+)
+
+// WorkspaceSource is a configured source directory of the node
+type WorkspaceSource struct {
+	Name    string            // Source name, e.g. "project" or "launcher"
+	Dir     string            // Source directory
+	Repo    config.RepoConfig // Remote the directory is cloned from if missing
+	Project bool              // The submodules and the LFS objects of the shared workspace configuration apply to the project only
+}
+
+// WorkspaceSources returns the configured source directories.
+func WorkspaceSources() []WorkspaceSource {
+	sources := []WorkspaceSource{
+		{"project", config.Unreal.Project.Directory, config.Unreal.Project.Repo, true},
+		{"launcher", config.ClientLauncher.SourceDir, config.ClientLauncher.Repo, false},
+		{"server-launcher", config.ServerLauncher.SourceDir, config.ServerLauncher.Repo, false},
+		{"pixel-streaming-launcher", config.PixelStreamingLauncher.SourceDir, config.PixelStreamingLauncher.Repo, false},
+	}
+
+	var configured []WorkspaceSource
+	for _, s := range sources {
+		if s.Dir != "" {
+			configured = append(configured, s)
+		}
+	}
+
+	return configured
+}
+
+// FindWorkspaceSource returns the configured source directory by its name.
+func FindWorkspaceSource(name string) (WorkspaceSource, error) {
+	var names []string
+	for _, s := range WorkspaceSources() {
+		if s.Name == name {
+			return s, nil
+		}
+		names = append(names, s.Name)
+	}
+
+	return WorkspaceSource{}, fmt.Errorf("invalid workspace source %s, configured sources: %s", name, strings.Join(names, ", "))
+}
+
+// syncWorkspace cleans the workspace and syncs the directory to the ref with the submodules and the LFS objects of the
+// target and platform, retried on transient failures.
+func syncWorkspace(ctx context.Context, shared *config.SharedConfig, source WorkspaceSource, ref string, target, platform string) (*git.Workspace, *git.ResolvedRef, error) {
+	options := git.SyncOptions{}
+	if source.Project {
+		options.Submodules = shared.Workspace.Submodules
+		options.Lfs = shared.Workspace.Lfs
+		options.LfsInclude, options.LfsExclude = shared.LfsFilter(target, platform)
+	}
+
+	var (
+		workspace *git.Workspace
+		resolved  *git.ResolvedRef
+	)
+	err := failure.Retry(ctx, shared, failure.PhaseSync, func() error {
+		// Reset and clean the leftovers of the previous jobs, so they do not leak into the build
+		var err error
+		if workspace, err = git.CleanWorkspace(ctx, source.Dir, shared.Workspace.Keep); err != nil {
+			return err
+		}
+
+		// Checkout the tag, branch or commit and verify HEAD points to it
+		if resolved, err = git.Sync(ctx, source.Dir, ref, options); err != nil {
+			return err
+		}
+
+		workspace.Ref, workspace.RefKind, workspace.Commit = resolved.Name, resolved.Kind, resolved.Commit
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return workspace, resolved, nil
+}
+
+// ReadWorkspaceState reads the checked out ref, the dirty files and, for the project with LFS enabled, the LFS objects of
+// the source directory.
+func ReadWorkspaceState(ctx context.Context, source WorkspaceSource) (*git.State, error) {
+	if !git.IsRepo(source.Dir) {
+		return nil, fmt.Errorf("invalid workspace: %s is not a git repository", source.Dir)
+	}
+
+	return git.ReadState(ctx, source.Dir, source.Project && config.SharedConfiguration().Workspace.Lfs)
+}
+
+// SyncWorkspace clones the source directory if it is missing, then cleans it and syncs it to the ref the same way the
+// jobs do, e.g. to fix the checkout of a node. The LFS filter of the target and platform is applied to the project.
+func SyncWorkspace(ctx context.Context, source WorkspaceSource, ref string, target, platform string) (*git.Workspace, error) {
+	shared := config.SharedConfiguration()
+
+	if err := ensureRepo(ctx, shared, source.Dir, source.Repo); err != nil {
+		return nil, err
+	}
+
+	workspace, _, err := syncWorkspace(ctx, shared, source, ref, target, platform)
+	return workspace, err
+}
+
+// CleanWorkspace resets the source directory and removes the untracked and ignored files except the kept ones.
+func CleanWorkspace(ctx context.Context, source WorkspaceSource) (*git.Workspace, error) {
+	if !git.IsRepo(source.Dir) {
+		return nil, fmt.Errorf("invalid workspace: %s is not a git repository", source.Dir)
+	}
+
+	return git.CleanWorkspace(ctx, source.Dir, config.SharedConfiguration().Workspace.Keep)
+}
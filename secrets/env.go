@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Getenv returns the secret value of the environment variable name. If the variable is not defined, the value is read
+// from the file referenced by the name_FILE variable (e.g. API_PASSWORD_FILE), trailing newlines are trimmed.
+// The returned value is registered in the registry, so it is redacted from logs and command output.
+func Getenv(name string) (string, error) {
+	value := os.Getenv(name)
+
+	if value == "" {
+		path := os.Getenv(name + "_FILE")
+		if path == "" {
+			return "", nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+
+		value = strings.TrimRight(string(b), "\r\n")
+	}
+
+	Register(value)
+
+	return value, nil
+}
+
+// Credentials contains credentials loaded from the credentials file
+type Credentials struct {
+	Email    string
+	Password string
+}
+
+// LoadCredentials reads the credentials file at path. The file contains "key=value" lines with "email" and "password"
+// keys, empty lines and lines starting with "#" are ignored, values are not trimmed except for the line endings.
+// The legacy "email:password" single line format is still supported, the password may contain ":" in both formats.
+// On Unix systems the file must not be accessible by the group or others (0600).
+func LoadCredentials(path string) (*Credentials, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// File permissions are not meaningful on Windows
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s has insecure permissions %#o, expected 0600", path, info.Mode().Perm())
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parseCredentials(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+
+	Register(c.Password)
+
+	return c, nil
+}
+
+// parseCredentials parses the credentials file content in either the key=value or the legacy email:password format.
+func parseCredentials(b []byte) (*Credentials, error) {
+	c := &Credentials{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok && !strings.Contains(key, ":") {
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "email":
+				c.Email = strings.TrimSpace(value)
+			case "password":
+				c.Password = value
+			default:
+				return nil, fmt.Errorf("unknown key: %s", strings.TrimSpace(key))
+			}
+			continue
+		}
+
+		// Legacy format, split on the first colon as emails can not contain it
+		if email, password, ok := strings.Cut(line, ":"); ok && c.Email == "" && c.Password == "" {
+			c.Email = strings.TrimSpace(email)
+			c.Password = password
+			continue
+		}
+
+		return nil, fmt.Errorf("invalid line, expected key=value")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.Email == "" || c.Password == "" {
+		return nil, fmt.Errorf("email or password is missing")
+	}
+
+	return c, nil
+}
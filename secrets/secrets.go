@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask is the replacement for secret values in redacted strings
+const Mask = "********"
+
+// minLength is the minimum length of a secret value to be registered, shorter values would redact too much of unrelated text
+const minLength = 4
+
+var (
+	mu       sync.RWMutex
+	values   = map[string]struct{}{}
+	replacer = strings.NewReplacer()
+)
+
+// Register adds the secret values to the registry, empty and too short values are ignored.
+func Register(secrets ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	changed := false
+	for _, s := range secrets {
+		s = strings.TrimSpace(s)
+		if len(s) < minLength {
+			continue
+		}
+		if _, ok := values[s]; !ok {
+			values[s] = struct{}{}
+			changed = true
+		}
+	}
+
+	if changed {
+		rebuildReplacer()
+	}
+}
+
+// rebuildReplacer recreates the replacer from the registered values, must be called with the lock held.
+func rebuildReplacer() {
+	// Sort the values by length so longer secrets are replaced before their substrings
+	sorted := make([]string, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	var oldNew []string
+	for _, v := range sorted {
+		oldNew = append(oldNew, v, Mask)
+	}
+	replacer = strings.NewReplacer(oldNew...)
+}
+
+// Redact replaces all registered secret values in the string s with the Mask.
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if len(values) == 0 {
+		return s
+	}
+
+	return replacer.Replace(s)
+}
+
+// RedactBytes replaces all registered secret values in b with the Mask.
+func RedactBytes(b []byte) []byte {
+	return []byte(Redact(string(b)))
+}
+
+// RedactAll replaces all registered secret values in every string of the slice, the original slice is not modified.
+func RedactAll(s []string) []string {
+	redacted := make([]string, len(s))
+	for i, v := range s {
+		redacted[i] = Redact(v)
+	}
+	return redacted
+}
@@ -0,0 +1,285 @@
+package unreal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"l7-cloud-builder/cmd"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/unreallog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// automationTestFailuresListed is the number of the failed tests listed in the error message
+const automationTestFailuresListed = 10
+
+// AutomationTestCase is the result of a single automation test
+type AutomationTestCase struct {
+	Name   string   `json:"name"`             // Test display name
+	Path   string   `json:"path"`             // Full test path, e.g. "Project.Functional Tests.Maps.Lobby"
+	State  string   `json:"state"`            // Test state, e.g. "Success", "Fail", "NotRun"
+	Errors []string `json:"errors,omitempty"` // Error messages of the failed test
+}
+
+// AutomationTestReport is the summary of the automation test report written by the Editor
+type AutomationTestReport struct {
+	Path                  string               `json:"path"` // Path to the report file
+	Succeeded             int                  `json:"succeeded"`
+	SucceededWithWarnings int                  `json:"succeededWithWarnings"`
+	Failed                int                  `json:"failed"`
+	NotRun                int                  `json:"notRun"`
+	Duration              float64              `json:"duration"` // Total duration of the tests in seconds
+	Tests                 []AutomationTestCase `json:"tests"`
+}
+
+// Passed returns the number of the passed tests including the ones passed with warnings.
+func (r *AutomationTestReport) Passed() int {
+	return r.Succeeded + r.SucceededWithWarnings
+}
+
+// Total returns the number of the tests in the report.
+func (r *AutomationTestReport) Total() int {
+	return r.Passed() + r.Failed + r.NotRun
+}
+
+// Message returns the pass/fail counts reported in the job status, e.g. "tests: 10 passed, 2 failed, 1 not run".
+func (r *AutomationTestReport) Message() string {
+	return fmt.Sprintf("tests: %d passed, %d failed, %d not run", r.Passed(), r.Failed, r.NotRun)
+}
+
+// AutomationTestError is returned when the automation tests fail or none of the tests run
+type AutomationTestError struct {
+	Report *AutomationTestReport
+}
+
+// Error returns the error message with the pass/fail counts and the failed tests.
+func (e *AutomationTestError) Error() string {
+	if e.Report.Total() == 0 {
+		return "automation tests failed: no tests matched the filter"
+	}
+
+	var failed []string
+	for _, t := range e.Report.Tests {
+		if t.State != "Fail" {
+			continue
+		}
+		if len(failed) == automationTestFailuresListed {
+			failed = append(failed, "...")
+			break
+		}
+		if len(t.Errors) > 0 {
+			failed = append(failed, fmt.Sprintf("%s (%s)", t.Path, t.Errors[0]))
+		} else {
+			failed = append(failed, t.Path)
+		}
+	}
+
+	message := fmt.Sprintf("automation tests failed, %s", e.Report.Message())
+	if len(failed) > 0 {
+		message += ": " + strings.Join(failed, "; ")
+	}
+	return message
+}
+
+// indexReport is the JSON report written by the Editor to the report export directory
+type indexReport struct {
+	Succeeded             int     `json:"succeeded"`
+	SucceededWithWarnings int     `json:"succeededWithWarnings"`
+	Failed                int     `json:"failed"`
+	NotRun                int     `json:"notRun"`
+	TotalDuration         float64 `json:"totalDuration"`
+	Tests                 []struct {
+		TestDisplayName string `json:"testDisplayName"`
+		FullTestPath    string `json:"fullTestPath"`
+		State           string `json:"state"`
+		Entries         []struct {
+			Event struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"event"`
+		} `json:"entries"`
+	} `json:"tests"`
+}
+
+// junitReport is the JUnit report, written by Gauntlet or the Editor with -ReportExportPath on the newer engine versions
+type junitReport struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Time  float64 `xml:"time,attr"`
+	Cases []struct {
+		Name      string `xml:"name,attr"`
+		ClassName string `xml:"classname,attr"`
+		Failures  []struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"failure"`
+		Errors []struct {
+			Message string `xml:"message,attr"`
+		} `xml:"error"`
+		Skipped *struct{} `xml:"skipped"`
+	} `xml:"testcase"`
+}
+
+// readIndexReport reads the Editor JSON report, the file is written with the UTF-8 byte order mark.
+func readIndexReport(path string) (*AutomationTestReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var index indexReport
+	if err = json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse automation test report %s: %w", path, err)
+	}
+
+	report := &AutomationTestReport{
+		Path:                  path,
+		Succeeded:             index.Succeeded,
+		SucceededWithWarnings: index.SucceededWithWarnings,
+		Failed:                index.Failed,
+		NotRun:                index.NotRun,
+		Duration:              index.TotalDuration,
+	}
+	for _, t := range index.Tests {
+		test := AutomationTestCase{Name: t.TestDisplayName, Path: t.FullTestPath, State: t.State}
+		for _, e := range t.Entries {
+			if e.Event.Type == "Error" {
+				test.Errors = append(test.Errors, e.Event.Message)
+			}
+		}
+		report.Tests = append(report.Tests, test)
+	}
+
+	return report, nil
+}
+
+// readJUnitReport reads the JUnit report, both the <testsuites> and the single <testsuite> root elements are supported.
+func readJUnitReport(path string) (*AutomationTestReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var junit junitReport
+	if err = xml.Unmarshal(data, &junit); err != nil || len(junit.Suites) == 0 {
+		var suite junitSuite
+		if err = xml.Unmarshal(data, &suite); err != nil {
+			return nil, fmt.Errorf("failed to parse automation test report %s: %w", path, err)
+		}
+		junit.Suites = []junitSuite{suite}
+	}
+
+	report := &AutomationTestReport{Path: path}
+	for _, s := range junit.Suites {
+		report.Duration += s.Time
+		for _, c := range s.Cases {
+			test := AutomationTestCase{Name: c.Name, Path: c.Name, State: "Success"}
+			if c.ClassName != "" {
+				test.Path = c.ClassName + "." + c.Name
+			}
+			for _, f := range c.Failures {
+				test.Errors = append(test.Errors, strings.TrimSpace(f.Message+" "+f.Text))
+			}
+			for _, e := range c.Errors {
+				test.Errors = append(test.Errors, e.Message)
+			}
+
+			switch {
+			case len(test.Errors) > 0:
+				test.State = "Fail"
+				report.Failed++
+			case c.Skipped != nil:
+				test.State = "NotRun"
+				report.NotRun++
+			default:
+				report.Succeeded++
+			}
+			report.Tests = append(report.Tests, test)
+		}
+	}
+
+	return report, nil
+}
+
+// ReadAutomationTestReport reads the automation test report from the report directory, the Editor JSON report
+// (index.json) is preferred, a JUnit report (*.xml) is used otherwise.
+func ReadAutomationTestReport(reportDir string) (*AutomationTestReport, error) {
+	index := filepath.Join(reportDir, "index.json")
+	if _, err := os.Stat(index); err == nil {
+		return readIndexReport(index)
+	}
+
+	junit, err := filepath.Glob(filepath.Join(reportDir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(junit) == 0 {
+		return nil, fmt.Errorf("failed to find automation test report in %s", reportDir)
+	}
+
+	return readJUnitReport(junit[0])
+}
+
+// RunAutomationTests runs the project automation tests matching the filter headless with the Editor, e.g. "Project" or
+// "Project.Functional Tests+Project.Unit", and reads the report exported to the report directory. The Editor exits
+// with an error code when tests fail, so the report is read regardless of the exit code. Returns the report and an
+// AutomationTestError if any test fails or no tests run.
+func RunAutomationTests(ctx context.Context, editorPath string, projectDir string, project string, filter string, reportDir string) (*AutomationTestReport, error) {
+	// Remove the previous report, so a stale report is never read
+	if err := os.RemoveAll(reportDir); err != nil {
+		return nil, fmt.Errorf("failed to clean automation test report directory: %w", err)
+	}
+
+	parser := unreallog.NewParser()
+	started := time.Now()
+
+	var editor = &cmd.Cmd{
+		Command:     editorPath,
+		CommandLine: `{project} "-ExecCmds=Automation RunTests {filter};Quit" -ReportExportPath={reportDir} -nullrhi -unattended -nopause -nosound -nosplash -buildmachine -stdout -FullStdOutLogOutput`,
+		WorkingDir:  projectDir,
+		Placeholders: map[string]string{
+			"project":   ProjectDescriptorPath(projectDir, project),
+			"filter":    filter,
+			"reportDir": reportDir,
+		},
+		Env:               config.Unreal.Environment,
+		Timeout:           config.Unreal.Limits.Timeout,
+		Nice:              config.Unreal.Limits.Nice,
+		MemoryLimit:       config.Unreal.Limits.MemoryLimit,
+		InactivityTimeout: config.Unreal.Limits.InactivityTimeout,
+		OnOutput:          parser.Line,
+	}
+
+	runErr := editor.Run(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	report, err := ReadAutomationTestReport(reportDir)
+	if err != nil {
+		if runErr != nil {
+			// Parse the logs written by the Editor, the tests did not complete
+			if _, err1 := parser.ReadDir(unreallog.ProjectLogsDir(projectDir), started); err1 != nil && !os.IsNotExist(err1) {
+				logger.Logger.Warningf("failed to parse project logs: %v", err1)
+			}
+			return nil, fmt.Errorf("failed to run automation tests: %w", &AutomationToolError{Summary: parser.Summary(), Err: runErr})
+		}
+		return nil, err
+	}
+
+	if report.Failed > 0 || report.Total() == 0 {
+		return report, &AutomationTestError{Report: report}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,302 @@
+package unreal
+
+import (
+	"context"
+	"fmt"
+	"l7-cloud-builder/config"
+	"path/filepath"
+	"strings"
+)
+
+// Configurations supported by the Unreal Engine builds
+var configurations = map[string]bool{
+	"Debug":       true,
+	"DebugGame":   true,
+	"Development": true,
+	"Test":        true,
+	"Shipping":    true,
+}
+
+// IniOverride overrides a config value for the command, rendered as -ini:<File>:[<Section>]:<Key>=<Value>
+type IniOverride struct {
+	File    string `json:"file"`    // Config file name without the platform and the extension, e.g. "Game" or "Engine"
+	Section string `json:"section"` // Config section, e.g. "/Script/UnrealEd.ProjectPackagingSettings"
+	Key     string `json:"key"`     // Config key, e.g. "BlueprintNativizationMethod"
+	Value   string `json:"value"`   // Config value, e.g. "Disabled"
+}
+
+// String returns the override as the command line argument.
+func (o IniOverride) String() string {
+	return fmt.Sprintf("-ini:%s:[%s]:%s=%s", o.File, o.Section, o.Key, o.Value)
+}
+
+// BuildCookRun contains the arguments of the Unreal Automation Tool BuildCookRun command, use Args to render them
+type BuildCookRun struct {
+	Project       string            // Path to the project descriptor, e.g. "X:/Metaverse/Metaverse.uproject"
+	UnrealExe     string            // Path to the editor used to cook the content, e.g. "X:/UnrealEngine/Engine/Binaries/Win64/UnrealEditor-Cmd.exe"
+	Target        config.TargetType // Target to build: client (the game target), server or editor
+	Platform      string            // Target platform, e.g. "Win64" or "Linux"
+	Configuration string            // Target configuration, e.g. "Development" or "Shipping"
+
+	Build      bool     // Build the target binaries
+	Cook       bool     // Cook the content
+	Maps       []string // Maps to cook, all maps from the packaging settings are cooked if empty
+	Pak        bool     // Pack the cooked content into pak files
+	IoStore    bool     // Use the I/O store containers, requires Pak
+	Compressed bool     // Compress the pak files, requires Pak
+
+	Stage            bool   // Stage the build
+	StagingDirectory string // Directory to stage the build to, requires Stage, the project Saved/StagedBuilds directory is used if empty
+	Package          bool   // Package the staged build, requires Stage
+	Archive          bool   // Archive the staged build, requires Stage
+	ArchiveDirectory string // Directory to archive the build to, requires Archive
+
+	CreateReleaseVersion  string // Release version to create, used to base patches and DLC on, requires Pak
+	BasedOnReleaseVersion string // Release version to base the build on, requires Pak
+	DLCName               string // Name of the DLC plugin to build, requires BasedOnReleaseVersion
+
+	Distribution  bool // Build for distribution, requires the Shipping configuration
+	Prerequisites bool // Include the prerequisites installer
+	CrashReporter bool // Include the crash reporter
+	DebugInfo     bool // Stage the debug files, e.g. the symbols used to symbolicate the crash reports
+	Debug         bool // Run the Automation Tool with the debug output
+
+	Ini       []IniOverride // Config values overridden for the command
+	ExtraArgs []string      // Additional arguments appended as is, e.g. from the shared configuration
+}
+
+// NewBuildCookRun creates the BuildCookRun arguments for the project and target with the defaults used by the builder:
+// build, cook, pak and compress the content, stage and package the build. The Shipping configuration is built for
+// distribution with the prerequisites and the crash reporter.
+func NewBuildCookRun(project string, target config.TargetType, platform string, configuration string) *BuildCookRun {
+	b := &BuildCookRun{
+		Project:       project,
+		Target:        target,
+		Platform:      platform,
+		Configuration: configuration,
+		Build:         true,
+	}
+
+	// The editor target is only built
+	if target == config.TargetTypeEditor {
+		return b
+	}
+
+	b.Cook = true
+	b.Pak = true
+	b.Compressed = true
+	b.Stage = true
+	b.Package = true
+
+	if configuration == "Shipping" {
+		b.Distribution = true
+		b.Prerequisites = true
+		b.CrashReporter = true
+	}
+
+	return b
+}
+
+// Validate checks the required arguments and the flag combinations.
+func (b *BuildCookRun) Validate() error {
+	var problems []string
+
+	if b.Project == "" {
+		problems = append(problems, "project is required")
+	}
+
+	if b.Platform == "" {
+		problems = append(problems, "platform is required")
+	}
+
+	if !configurations[b.Configuration] {
+		problems = append(problems, fmt.Sprintf("unsupported configuration %q", b.Configuration))
+	}
+
+	switch b.Target {
+	case config.TargetTypeClient, config.TargetTypeServer:
+	case config.TargetTypeEditor:
+		// The editor target uses the uncooked content
+		if b.Cook || b.Pak || b.Stage || b.Package || b.Archive {
+			problems = append(problems, "editor target can not be cooked, packed, staged, packaged or archived")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported target %q", config.Config.TargetMapping[b.Target]))
+	}
+
+	if !b.Build && !b.Cook && !b.Stage {
+		problems = append(problems, "nothing to do, at least one of build, cook or stage is required")
+	}
+
+	if len(b.Maps) > 0 && !b.Cook {
+		problems = append(problems, "maps require cook")
+	}
+
+	if b.Pak && !b.Cook {
+		problems = append(problems, "pak requires cook")
+	}
+
+	if b.IoStore && !b.Pak {
+		problems = append(problems, "IoStore requires pak")
+	}
+
+	if b.Compressed && !b.Pak {
+		problems = append(problems, "compressed requires pak")
+	}
+
+	if b.StagingDirectory != "" && !b.Stage {
+		problems = append(problems, "staging directory requires stage")
+	}
+
+	if b.Package && !b.Stage {
+		problems = append(problems, "package requires stage")
+	}
+
+	if b.Archive && !b.Stage {
+		problems = append(problems, "archive requires stage")
+	}
+
+	if b.ArchiveDirectory != "" && !b.Archive {
+		problems = append(problems, "archive directory requires archive")
+	}
+
+	if (b.CreateReleaseVersion != "" || b.BasedOnReleaseVersion != "") && !b.Pak {
+		problems = append(problems, "release versions require pak")
+	}
+
+	if b.DLCName != "" && b.BasedOnReleaseVersion == "" {
+		problems = append(problems, "DLC requires a release version to be based on")
+	}
+
+	if b.DLCName != "" && b.CreateReleaseVersion != "" {
+		problems = append(problems, "DLC can not create a release version")
+	}
+
+	if b.Distribution && b.Configuration != "Shipping" {
+		problems = append(problems, "distribution requires the Shipping configuration")
+	}
+
+	for _, o := range b.Ini {
+		if o.File == "" || o.Section == "" || o.Key == "" {
+			problems = append(problems, fmt.Sprintf("invalid ini override %s", o))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid BuildCookRun arguments: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Args returns the command line arguments of the Automation Tool in a stable order, call Validate first.
+func (b *BuildCookRun) Args() []string {
+	args := []string{"BuildCookRun", "-project=" + b.Project, "-noP4"}
+
+	if b.UnrealExe != "" {
+		args = append(args, "-unrealexe="+b.UnrealExe)
+	}
+
+	// Target, platform and configuration
+	switch b.Target {
+	case config.TargetTypeServer:
+		args = append(args, "-server", "-noclient", "-serverplatform="+b.Platform, "-serverconfig="+b.Configuration)
+	case config.TargetTypeEditor:
+		project := strings.TrimSuffix(filepath.Base(b.Project), filepath.Ext(b.Project))
+		args = append(args, "-target="+project+"Editor", "-platform="+b.Platform, "-clientconfig="+b.Configuration)
+	default:
+		args = append(args, "-platform="+b.Platform, "-clientconfig="+b.Configuration)
+	}
+
+	for _, o := range b.Ini {
+		args = append(args, o.String())
+	}
+
+	if b.Build {
+		args = append(args, "-build")
+	}
+
+	if b.Cook {
+		args = append(args, "-cook", "-unversionedcookedcontent", "-SkipCookingEditorContent")
+		if len(b.Maps) > 0 {
+			args = append(args, "-map="+strings.Join(b.Maps, "+"))
+		}
+	}
+
+	if b.Pak {
+		args = append(args, "-pak")
+	}
+
+	if b.IoStore {
+		args = append(args, "-iostore")
+	}
+
+	if b.Compressed {
+		args = append(args, "-compressed")
+	}
+
+	if b.Package {
+		args = append(args, "-package")
+	}
+
+	if b.CreateReleaseVersion != "" {
+		args = append(args, "-createreleaseversion="+b.CreateReleaseVersion)
+	}
+
+	if b.BasedOnReleaseVersion != "" {
+		args = append(args, "-basedonreleaseversion="+b.BasedOnReleaseVersion)
+	}
+
+	if b.DLCName != "" {
+		args = append(args, "-dlcname="+b.DLCName)
+	}
+
+	if b.Stage {
+		args = append(args, "-stage")
+		if b.StagingDirectory != "" {
+			args = append(args, "-stagingdirectory="+b.StagingDirectory)
+		}
+	}
+
+	if b.Archive {
+		args = append(args, "-archive")
+		if b.ArchiveDirectory != "" {
+			args = append(args, "-archivedirectory="+b.ArchiveDirectory)
+		}
+	}
+
+	if b.CrashReporter {
+		args = append(args, "-CrashReporter")
+	}
+
+	if b.Distribution {
+		args = append(args, "-distribution")
+	}
+
+	if b.Prerequisites {
+		args = append(args, "-prereqs")
+	}
+
+	if b.DebugInfo {
+		args = append(args, "-debuginfo")
+	}
+
+	if b.Debug {
+		args = append(args, "-debug")
+	}
+
+	// Unattended build machine options
+	args = append(args, "-VeryVerbose", "-NoCodeSign", "-BuildMachine", "-AllowCommandletRendering", "-utf8output")
+
+	return append(args, b.ExtraArgs...)
+}
+
+// Run validates the arguments and runs the BuildCookRun command with the Unreal Automation Tool.
+func (b *BuildCookRun) Run(ctx context.Context, workdir string, automationToolPath string) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	// The arguments are passed as a list, so they are not split or expanded
+	return RunAutomationTool(ctx, workdir, automationToolPath, "{args...}", nil, map[string][]string{"args": b.Args()})
+}
@@ -0,0 +1,145 @@
+package unreal
+
+import (
+	"l7-cloud-builder/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// machineArgs are the unattended build machine options appended to every command line
+var machineArgs = []string{"-VeryVerbose", "-NoCodeSign", "-BuildMachine", "-AllowCommandletRendering", "-utf8output"}
+
+func TestBuildCookRunArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *BuildCookRun
+		want  []string
+	}{
+		{
+			name: "development client",
+			build: func() *BuildCookRun {
+				return NewBuildCookRun("Metaverse.uproject", config.TargetTypeClient, "Win64", "Development")
+			},
+			want: []string{"BuildCookRun", "-project=Metaverse.uproject", "-noP4", "-platform=Win64", "-clientconfig=Development",
+				"-build", "-cook", "-unversionedcookedcontent", "-SkipCookingEditorContent", "-pak", "-compressed", "-package", "-stage"},
+		},
+		{
+			name: "shipping server",
+			build: func() *BuildCookRun {
+				return NewBuildCookRun("Metaverse.uproject", config.TargetTypeServer, "Linux", "Shipping")
+			},
+			want: []string{"BuildCookRun", "-project=Metaverse.uproject", "-noP4", "-server", "-noclient", "-serverplatform=Linux",
+				"-serverconfig=Shipping", "-build", "-cook", "-unversionedcookedcontent", "-SkipCookingEditorContent", "-pak",
+				"-compressed", "-package", "-stage", "-CrashReporter", "-distribution", "-prereqs"},
+		},
+		{
+			name: "editor",
+			build: func() *BuildCookRun {
+				return NewBuildCookRun("X:/Metaverse/Metaverse.uproject", config.TargetTypeEditor, "Win64", "Development")
+			},
+			want: []string{"BuildCookRun", "-project=X:/Metaverse/Metaverse.uproject", "-noP4", "-target=MetaverseEditor",
+				"-platform=Win64", "-clientconfig=Development", "-build"},
+		},
+		{
+			name: "release with maps, debug files and extra arguments",
+			build: func() *BuildCookRun {
+				b := NewBuildCookRun("Metaverse.uproject", config.TargetTypeClient, "Win64", "Development")
+				b.UnrealExe = "X:/UnrealEngine/Engine/Binaries/Win64/UnrealEditor-Cmd.exe"
+				b.Maps = []string{"/Game/Maps/Lobby", "/Game/Maps/World"}
+				b.CreateReleaseVersion = "1.2.0"
+				b.StagingDirectory = "X:/Staged/1.2.0"
+				b.Ini = []IniOverride{{File: "Game", Section: "BuildInfo", Key: "BuildId", Value: "42"}}
+				b.DebugInfo = true
+				b.Debug = true
+				b.ExtraArgs = []string{"-nodebuginfo"}
+				return b
+			},
+			want: []string{"BuildCookRun", "-project=Metaverse.uproject", "-noP4",
+				"-unrealexe=X:/UnrealEngine/Engine/Binaries/Win64/UnrealEditor-Cmd.exe", "-platform=Win64",
+				"-clientconfig=Development", "-ini:Game:[BuildInfo]:BuildId=42", "-build", "-cook", "-unversionedcookedcontent",
+				"-SkipCookingEditorContent", "-map=/Game/Maps/Lobby+/Game/Maps/World", "-pak", "-compressed", "-package",
+				"-createreleaseversion=1.2.0", "-stage", "-stagingdirectory=X:/Staged/1.2.0", "-debuginfo", "-debug"},
+		},
+		{
+			name: "DLC archive",
+			build: func() *BuildCookRun {
+				b := NewBuildCookRun("Metaverse.uproject", config.TargetTypeClient, "Win64", "Development")
+				b.Package = false
+				b.IoStore = true
+				b.BasedOnReleaseVersion = "1.0.0"
+				b.DLCName = "Season1"
+				b.Archive = true
+				b.ArchiveDirectory = "X:/Archive"
+				return b
+			},
+			want: []string{"BuildCookRun", "-project=Metaverse.uproject", "-noP4", "-platform=Win64", "-clientconfig=Development",
+				"-build", "-cook", "-unversionedcookedcontent", "-SkipCookingEditorContent", "-pak", "-iostore", "-compressed",
+				"-basedonreleaseversion=1.0.0", "-dlcname=Season1", "-stage", "-archive", "-archivedirectory=X:/Archive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.build()
+			if err := b.Validate(); err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+
+			// The machine options are followed by the extra arguments
+			want := append(append(tt.want, machineArgs...), b.ExtraArgs...)
+			if got := b.Args(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Args() =\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildCookRunValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(b *BuildCookRun)
+		want   string // Expected problem, empty if valid
+	}{
+		{name: "valid", modify: func(b *BuildCookRun) {}},
+		{name: "missing project", modify: func(b *BuildCookRun) { b.Project = "" }, want: "project is required"},
+		{name: "missing platform", modify: func(b *BuildCookRun) { b.Platform = "" }, want: "platform is required"},
+		{name: "unsupported configuration", modify: func(b *BuildCookRun) { b.Configuration = "Release" }, want: `unsupported configuration "Release"`},
+		{name: "nothing to do", modify: func(b *BuildCookRun) {
+			*b = BuildCookRun{Project: b.Project, Platform: b.Platform, Configuration: b.Configuration}
+		}, want: "nothing to do"},
+		{name: "maps without cook", modify: func(b *BuildCookRun) {
+			b.Cook, b.Pak, b.Compressed = false, false, false
+			b.Maps = []string{"/Game/Maps/Lobby"}
+		}, want: "maps require cook"},
+		{name: "pak without cook", modify: func(b *BuildCookRun) { b.Cook = false }, want: "pak requires cook"},
+		{name: "IoStore without pak", modify: func(b *BuildCookRun) { b.Pak, b.Compressed = false, false; b.IoStore = true }, want: "IoStore requires pak"},
+		{name: "compressed without pak", modify: func(b *BuildCookRun) { b.Pak = false }, want: "compressed requires pak"},
+		{name: "package without stage", modify: func(b *BuildCookRun) { b.Stage = false }, want: "package requires stage"},
+		{name: "archive directory without archive", modify: func(b *BuildCookRun) { b.ArchiveDirectory = "X:/Archive" }, want: "archive directory requires archive"},
+		{name: "DLC without base release", modify: func(b *BuildCookRun) { b.DLCName = "Season1" }, want: "DLC requires a release version to be based on"},
+		{name: "DLC creating release", modify: func(b *BuildCookRun) {
+			b.DLCName, b.BasedOnReleaseVersion, b.CreateReleaseVersion = "Season1", "1.0.0", "1.1.0"
+		}, want: "DLC can not create a release version"},
+		{name: "distribution without shipping", modify: func(b *BuildCookRun) { b.Distribution = true }, want: "distribution requires the Shipping configuration"},
+		{name: "invalid ini override", modify: func(b *BuildCookRun) { b.Ini = []IniOverride{{File: "Game", Value: "1"}} }, want: "invalid ini override"},
+		{name: "cooked editor", modify: func(b *BuildCookRun) { b.Target = config.TargetTypeEditor }, want: "editor target can not be cooked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuildCookRun("Metaverse.uproject", config.TargetTypeClient, "Win64", "Development")
+			tt.modify(b)
+
+			err := b.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Validate() = nil, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
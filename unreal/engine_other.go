@@ -0,0 +1,27 @@
+//go:build !windows
+
+package unreal
+
+import "strings"
+
+// registeredEngineDir returns the directory of the source engine build registered with the identifier in the engine
+// registry file.
+func registeredEngineDir(id string) (string, bool) {
+	path, err := InstallationsPath()
+	if err != nil {
+		return "", false
+	}
+
+	installations, err := ReadInstallations(path)
+	if err != nil {
+		return "", false
+	}
+
+	for k, dir := range installations {
+		if strings.EqualFold(strings.Trim(k, "{}"), strings.Trim(id, "{}")) {
+			return dir, true
+		}
+	}
+
+	return "", false
+}
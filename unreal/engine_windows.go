@@ -0,0 +1,24 @@
+package unreal
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// registeredEngineDir returns the directory of the source engine build registered with the identifier by the version
+// selector or the editor.
+func registeredEngineDir(id string) (string, bool) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, `SOFTWARE\Epic Games\Unreal Engine\Builds`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", false
+	}
+	defer key.Close()
+
+	// Identifiers are stored with or without braces depending on the engine version
+	for _, name := range []string{id, "{" + id + "}"} {
+		if dir, _, err := key.GetStringValue(name); err == nil && dir != "" {
+			return dir, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,312 @@
+package unreal
+
+import (
+	"encoding/json"
+	"fmt"
+	"l7-cloud-builder/config"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Engine is an installed Unreal Engine
+type Engine struct {
+	Id                 string `json:"id"`         // Engine identifier advertised to the API, e.g. "5.1" or "5.1-source"
+	Version            string `json:"version"`    // Engine version, e.g. "5.1.1"
+	Changelist         int    `json:"changelist"` // Engine changelist, zero for source builds without a changelist
+	Branch             string `json:"branch"`     // Engine branch name, e.g. "++UE5+Release-5.1"
+	Source             bool   `json:"source"`     // Source build, the launcher and installed builds are not source builds
+	RootDir            string `json:"rootDir"`    // Engine root directory containing the Engine directory
+	AutomationToolPath string `json:"-"`          // Path to the Unreal Automation Tool
+	EditorPath         string `json:"-"`          // Path to the UnrealEditor-Cmd executable
+	Association        string `json:"-"`          // Engine version the project is switched to, the root directory for source builds
+}
+
+// buildVersion is the engine build version file (Engine/Build/Build.version)
+type buildVersion struct {
+	MajorVersion int    `json:"MajorVersion"`
+	MinorVersion int    `json:"MinorVersion"`
+	PatchVersion int    `json:"PatchVersion"`
+	Changelist   int    `json:"Changelist"`
+	BranchName   string `json:"BranchName"`
+}
+
+var (
+	enginesMu sync.RWMutex
+	engines   []*Engine // Installed engines, the configured engines go first
+)
+
+// binariesPlatform returns the engine binaries directory name for the current platform.
+func binariesPlatform() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "Win64"
+	case "darwin":
+		return "Mac"
+	default:
+		return "Linux"
+	}
+}
+
+// firstExisting returns the first existing path or the first path if none exist.
+func firstExisting(paths ...string) string {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return paths[0]
+}
+
+// LoadEngine reads the engine installed in the root directory, the identifier is the version for the installed builds
+// or the directory name for the source builds if empty.
+func LoadEngine(rootDir string, id string) (*Engine, error) {
+	engineDir := filepath.Join(rootDir, "Engine")
+
+	data, err := os.ReadFile(filepath.Join(engineDir, "Build", "Build.version"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read engine version in %s: %w", rootDir, err)
+	}
+
+	var v buildVersion
+	if err = json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid engine version in %s: %w", rootDir, err)
+	}
+
+	e := &Engine{
+		Id:         id,
+		Version:    fmt.Sprintf("%d.%d.%d", v.MajorVersion, v.MinorVersion, v.PatchVersion),
+		Changelist: v.Changelist,
+		Branch:     v.BranchName,
+		RootDir:    filepath.Clean(rootDir),
+	}
+
+	// Launcher and installed builds are marked with the InstalledBuild.txt file
+	if _, err = os.Stat(filepath.Join(engineDir, "Build", "InstalledBuild.txt")); os.IsNotExist(err) {
+		e.Source = true
+	}
+
+	// Source builds are associated by the root directory, installed builds by the version
+	if e.Source {
+		e.Association = e.RootDir
+	} else {
+		e.Association = fmt.Sprintf("%d.%d", v.MajorVersion, v.MinorVersion)
+	}
+
+	if e.Id == "" {
+		if e.Source {
+			e.Id = filepath.Base(e.RootDir)
+		} else {
+			e.Id = e.Association
+		}
+	}
+
+	// Select the tools of the current platform, Unreal Engine 4 uses the UE4 prefix
+	binaries := filepath.Join(engineDir, "Binaries", binariesPlatform())
+	exe := ""
+	if runtime.GOOS == "windows" {
+		exe = ".exe"
+	}
+	e.EditorPath = firstExisting(filepath.Join(binaries, "UnrealEditor-Cmd"+exe), filepath.Join(binaries, "UE4Editor-Cmd"+exe))
+	e.AutomationToolPath = firstExisting(
+		filepath.Join(engineDir, "Binaries", "DotNET", "AutomationTool", "AutomationTool"+exe),
+		filepath.Join(engineDir, "Binaries", "DotNET", "AutomationTool"+exe),
+	)
+
+	return e, nil
+}
+
+// ScanEngines returns the engines installed in the subdirectories of the directory.
+func ScanEngines(dir string) ([]*Engine, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan engines in %s: %w", dir, err)
+	}
+
+	var result []*Engine
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		rootDir := filepath.Join(dir, entry.Name())
+		if _, err = os.Stat(filepath.Join(rootDir, "Engine", "Build", "Build.version")); err != nil {
+			continue
+		}
+
+		e, err := LoadEngine(rootDir, "")
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// LoadEngines loads the configured engines and scans the engine directories, the loaded engines replace the
+// previously loaded ones. Engines with the same identifier or root directory are loaded once.
+func LoadEngines(configured []config.EngineConfig, dirs []string) ([]*Engine, error) {
+	var result []*Engine
+
+	add := func(e *Engine) {
+		for _, existing := range result {
+			if strings.EqualFold(existing.Id, e.Id) || samePath(existing.RootDir, e.RootDir) {
+				return
+			}
+		}
+		result = append(result, e)
+	}
+
+	for _, c := range configured {
+		e, err := LoadEngine(c.RootDir, c.Id)
+		if err != nil {
+			return nil, err
+		}
+		add(e)
+	}
+
+	for _, dir := range dirs {
+		scanned, err := ScanEngines(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range scanned {
+			add(e)
+		}
+	}
+
+	enginesMu.Lock()
+	engines = result
+	enginesMu.Unlock()
+
+	return result, nil
+}
+
+// Engines returns the loaded engines.
+func Engines() []*Engine {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+	return append([]*Engine(nil), engines...)
+}
+
+// EngineTags returns the identifiers and the major.minor versions of the loaded engines advertised to the API, so the
+// jobs requiring an engine are only fetched by the nodes having it.
+func EngineTags() []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, e := range Engines() {
+		version := e.Version
+		if parts := strings.SplitN(version, ".", 3); len(parts) == 3 {
+			version = parts[0] + "." + parts[1]
+		}
+
+		for _, tag := range []string{e.Id, version} {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// CodeEngine returns the source code engine configured with the engine paths, used when no engines are loaded. The
+// engine is read from the root directory of the configured editor, so the project is associated with the engine root
+// directory and never with the code version.
+func CodeEngine() (*Engine, error) {
+	engineDir := EngineDir(config.Unreal.Code.EditorPath)
+	if engineDir == "" {
+		return nil, fmt.Errorf("invalid engine: editor %s is not inside an engine directory", config.Unreal.Code.EditorPath)
+	}
+
+	e, err := LoadEngine(filepath.Dir(engineDir), "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the configured tools
+	e.AutomationToolPath = config.Unreal.Code.AutomationToolPath
+	e.EditorPath = config.Unreal.Code.EditorPath
+
+	return e, nil
+}
+
+// engineMatches checks if the engine identifier or the version matches, e.g. "5.1" matches "5.1.1".
+func engineMatches(e *Engine, association string) bool {
+	if strings.EqualFold(strings.Trim(e.Id, "{}"), strings.Trim(association, "{}")) {
+		return true
+	}
+	return association != "" && (e.Version == association || strings.HasPrefix(e.Version, association+"."))
+}
+
+// ResolveEngine returns the loaded engine the project engine association refers to: the engine identifier, the
+// registered source build identifier or the engine version, e.g. "5.1".
+func ResolveEngine(association string) (*Engine, error) {
+	all := Engines()
+
+	// Engine identifier
+	for _, e := range all {
+		if strings.EqualFold(strings.Trim(e.Id, "{}"), strings.Trim(association, "{}")) {
+			return e, nil
+		}
+	}
+
+	// Source build registered with the identifier
+	if dir, ok := registeredEngineDir(association); ok {
+		for _, e := range all {
+			if samePath(e.RootDir, dir) {
+				return e, nil
+			}
+		}
+	}
+
+	// Engine version, e.g. "5.1" matches "5.1.1", installed builds are preferred
+	var match *Engine
+	for _, e := range all {
+		if engineMatches(e, association) {
+			if match == nil || (match.Source && !e.Source) {
+				match = e
+			}
+		}
+	}
+	if match != nil {
+		return match, nil
+	}
+
+	return nil, fmt.Errorf("engine %q is not installed on this node", association)
+}
+
+// ProjectEngine returns the engine to build the project with: the engine required by the release metadata, else the
+// engine the project descriptor is associated with. The source code engine is returned if no engines are loaded, it
+// must match the engine required by the release.
+func ProjectEngine(projectDir string, project string, releaseEngine string) (*Engine, error) {
+	if len(Engines()) == 0 {
+		e, err := CodeEngine()
+		if err != nil {
+			return nil, err
+		}
+
+		if releaseEngine != "" && !engineMatches(e, releaseEngine) {
+			return nil, fmt.Errorf("engine %q is not installed on this node, the source code engine is %s (%s)", releaseEngine, e.Id, e.Version)
+		}
+
+		return e, nil
+	}
+
+	// The release metadata takes precedence over the checked out project descriptor
+	if releaseEngine != "" {
+		return ResolveEngine(releaseEngine)
+	}
+
+	d, err := ReadProjectDescriptor(ProjectDescriptorPath(projectDir, project))
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolveEngine(d.EngineAssociation)
+}
@@ -0,0 +1,151 @@
+package unreal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IniFilePath returns the path to the project default config file, e.g. "Config/DefaultGame.ini" for "Game".
+func IniFilePath(projectDir string, file string) string {
+	return filepath.Join(projectDir, "Config", "Default"+file+".ini")
+}
+
+// isIniSection checks if the trimmed line is a section header and returns the section name.
+func isIniSection(line string) (string, bool) {
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		return line[1 : len(line)-1], true
+	}
+	return "", false
+}
+
+// isIniKey checks if the trimmed line sets the key, including the array operations, e.g. "+Key=Value".
+func isIniKey(line string, key string) bool {
+	line = strings.TrimLeft(line, "+-.!")
+	name, _, ok := strings.Cut(line, "=")
+	if !ok {
+		// Array clear operations have no value, e.g. "!Key=ClearArray" or "!Key"
+		name = line
+	}
+	return strings.EqualFold(strings.TrimSpace(name), key)
+}
+
+// setIniValue sets the key of the section to the value, the existing values of the key including the array operations
+// are replaced, the section is added if missing. The rest of the data is kept as is.
+func setIniValue(data []byte, section string, key string, value string) []byte {
+	text := string(data)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	entry := key + "=" + value
+	result := make([]string, 0, len(lines)+2)
+	inSection, found, set := false, false, false
+	insert := -1
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if name, ok := isIniSection(trimmed); ok {
+			inSection = name == section
+			found = found || inSection
+			result = append(result, line)
+			if inSection && !set {
+				insert = len(result)
+			}
+			continue
+		}
+
+		if inSection && isIniKey(trimmed, key) {
+			// Replace the first value in place and drop the rest
+			if !set {
+				result = append(result, entry)
+				set = true
+			}
+			continue
+		}
+
+		result = append(result, line)
+		if inSection && !set && trimmed != "" {
+			insert = len(result)
+		}
+	}
+
+	if !set {
+		if found {
+			result = append(result[:insert], append([]string{entry}, result[insert:]...)...)
+		} else {
+			if len(result) > 0 && strings.TrimSpace(result[len(result)-1]) != "" {
+				result = append(result, "")
+			}
+			result = append(result, "["+section+"]", entry)
+		}
+	}
+
+	return []byte(strings.Join(result, newline) + newline)
+}
+
+// ApplyIniOverrides applies the overrides to the project default config files, returns the function restoring the
+// original files, so the working tree stays clean after the build. The files are restored if applying fails.
+func ApplyIniOverrides(projectDir string, overrides []IniOverride) (func() error, error) {
+	// Original contents of the changed files, nil if the file did not exist
+	originals := map[string][]byte{}
+	var order []string
+
+	restore := func() error {
+		var problems []string
+		for i := len(order) - 1; i >= 0; i-- {
+			path := order[i]
+			var err error
+			if originals[path] == nil {
+				err = os.Remove(path)
+			} else {
+				err = os.WriteFile(path, originals[path], 0644)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				problems = append(problems, err.Error())
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("failed to restore config files: %s", strings.Join(problems, "; "))
+		}
+		return nil
+	}
+
+	for _, o := range overrides {
+		if o.File == "" || o.Section == "" || o.Key == "" {
+			_ = restore()
+			return nil, fmt.Errorf("invalid ini override %s", o)
+		}
+
+		path := IniFilePath(projectDir, o.File)
+
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			_ = restore()
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// Keep the original contents of the file before the first change
+		if _, ok := originals[path]; !ok {
+			if data == nil && err == nil {
+				data = []byte{}
+			}
+			originals[path] = data
+			order = append(order, path)
+		}
+
+		if err = os.WriteFile(path, setIniValue(data, o.Section, o.Key, o.Value), 0644); err != nil {
+			_ = restore()
+			return nil, fmt.Errorf("failed to write config file: %w", err)
+		}
+	}
+
+	return restore, nil
+}
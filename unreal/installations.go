@@ -0,0 +1,178 @@
+package unreal
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// installationsSection is the section of the engine registry file listing the source engine builds
+const installationsSection = "Installations"
+
+// engineAssociationRegexp matches the EngineAssociation value of the project descriptor
+var engineAssociationRegexp = regexp.MustCompile(`("EngineAssociation"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// fileVersionRegexp matches the FileVersion line of the project descriptor, the EngineAssociation is added after it if missing
+var fileVersionRegexp = regexp.MustCompile(`(?m)^([ \t]*)"FileVersion"\s*:\s*\d+\s*,[ \t]*\r?\n`)
+
+// InstallationsPath returns the path to the engine registry file used by the editor and the version selector on Linux
+// and macOS, e.g. "~/.config/Epic/UnrealEngine/Install.ini".
+func InstallationsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "Epic", "UnrealEngine", "Install.ini"), nil
+	}
+
+	return filepath.Join(home, ".config", "Epic", "UnrealEngine", "Install.ini"), nil
+}
+
+// ReadInstallations reads the source engine builds registered in the engine registry file, returns the engine root
+// directories by the identifier. Returns an empty map if the file does not exist.
+func ReadInstallations(path string) (map[string]string, error) {
+	installations := map[string]string{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return installations, nil
+		}
+		return nil, fmt.Errorf("failed to read engine installations: %w", err)
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+
+		if section != installationsSection {
+			continue
+		}
+
+		if id, dir, ok := strings.Cut(line, "="); ok {
+			installations[strings.TrimSpace(id)] = strings.TrimSpace(dir)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read engine installations: %w", err)
+	}
+
+	return installations, nil
+}
+
+// RegisterInstallation registers the source engine build root directory in the engine registry file with a new
+// identifier, returns the existing identifier if the directory is already registered.
+func RegisterInstallation(path string, engineRoot string) (string, error) {
+	installations, err := ReadInstallations(path)
+	if err != nil {
+		return "", err
+	}
+
+	for id, dir := range installations {
+		if samePath(dir, engineRoot) {
+			return id, nil
+		}
+	}
+
+	// Identifiers are GUIDs in braces, the same as the ones generated by the editor
+	guid, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate engine identifier: %w", err)
+	}
+	id := "{" + strings.ToUpper(guid.String()) + "}"
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read engine installations: %w", err)
+	}
+
+	// Add the entry to the section keeping the rest of the file as is
+	data = setIniValue(data, installationsSection, id, filepath.Clean(engineRoot))
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create engine installations directory: %w", err)
+	}
+
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write engine installations: %w", err)
+	}
+
+	return id, nil
+}
+
+// SetEngineAssociation updates the EngineAssociation of the project descriptor file keeping the rest of the file as is.
+func SetEngineAssociation(descriptorPath string, association string) error {
+	data, err := os.ReadFile(descriptorPath)
+	if err != nil {
+		return fmt.Errorf("failed to read project descriptor: %w", err)
+	}
+
+	// Make sure the descriptor is valid before changing it
+	if _, err = ReadProjectDescriptor(descriptorPath); err != nil {
+		return err
+	}
+
+	value := strings.ReplaceAll(association, `\`, `\\`)
+
+	if engineAssociationRegexp.Match(data) {
+		data = engineAssociationRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+			prefix := engineAssociationRegexp.FindSubmatch(match)[1]
+			return []byte(string(prefix) + `"` + value + `"`)
+		})
+	} else if loc := fileVersionRegexp.FindSubmatchIndex(data); loc != nil {
+		indent := string(data[loc[2]:loc[3]])
+		line := indent + `"EngineAssociation": "` + value + `",` + "\n"
+		data = append(data[:loc[1]:loc[1]], append([]byte(line), data[loc[1]:]...)...)
+	} else {
+		return fmt.Errorf("invalid project descriptor %s: failed to find where to set EngineAssociation", descriptorPath)
+	}
+
+	if err = os.WriteFile(descriptorPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write project descriptor: %w", err)
+	}
+
+	return nil
+}
+
+// switchEngineAssociation switches the project engine version without the version selector: a source engine root
+// directory is registered in the engine registry file and the project is associated with its identifier, other
+// versions, e.g. "5.1", are used as the identifier as is.
+func switchEngineAssociation(descriptorPath string, version string) error {
+	association := version
+
+	if info, err := os.Stat(version); err == nil && info.IsDir() {
+		// Make sure the directory is an engine root directory
+		if _, err = os.Stat(filepath.Join(version, "Engine")); err != nil {
+			return fmt.Errorf("invalid engine root directory %s: %w", version, err)
+		}
+
+		path, err := InstallationsPath()
+		if err != nil {
+			return err
+		}
+
+		if association, err = RegisterInstallation(path, version); err != nil {
+			return err
+		}
+	}
+
+	return SetEngineAssociation(descriptorPath, association)
+}
@@ -0,0 +1,296 @@
+package unreal
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MapsSourceRelease is the source of the maps listed in the release options
+	MapsSourceRelease = "release"
+	// MapsSourcePackagingSettings is the source of the maps listed in the project packaging settings
+	MapsSourcePackagingSettings = "packaging-settings"
+	// MapsSourceAll is used when no maps are listed, all the project maps are cooked
+	MapsSourceAll = "all"
+)
+
+// mapsToCookRegexp matches the map package path of the MapsToCook packaging setting, e.g. +MapsToCook=(FilePath="/Game/Maps/Lobby")
+var mapsToCookRegexp = regexp.MustCompile(`(?i)FilePath\s*=\s*"([^"]+)"`)
+
+// CookMaps is the resolved list of the maps to cook, recorded in the release report
+type CookMaps struct {
+	Maps   []string `json:"maps"`   // Map package paths, e.g. "/Game/Maps/Lobby"
+	Source string   `json:"source"` // Source of the list: release, packaging-settings or all
+}
+
+// contentMount is a content directory mounted to the package path root, e.g. "/Game" for the project Content directory
+type contentMount struct {
+	name string // Mount point name, e.g. "Game" or the plugin name
+	dir  string // Content directory
+}
+
+// mapIndex finds the maps of the project and the project plugins
+type mapIndex struct {
+	mounts map[string]contentMount // Content mounts by the lower case mount point name, e.g. "game"
+	names  map[string][]string     // Map package paths by the lower case map name, built on the first use
+}
+
+// newMapIndex finds the content directories of the project and the project plugins.
+func newMapIndex(projectDir string) (*mapIndex, error) {
+	idx := &mapIndex{
+		mounts: map[string]contentMount{"game": {name: "Game", dir: filepath.Join(projectDir, "Content")}},
+	}
+
+	// Plugin content is mounted by the plugin name
+	pluginsDir := filepath.Join(projectDir, "Plugins")
+	err := filepath.WalkDir(pluginsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == pluginsDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if entry.IsDir() {
+			switch entry.Name() {
+			case "Binaries", "Content", "Intermediate", "Resources", "Saved", "Source":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.EqualFold(filepath.Ext(path), ".uplugin") {
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(path))
+			idx.mounts[strings.ToLower(name)] = contentMount{name: name, dir: filepath.Join(filepath.Dir(path), "Content")}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find plugins in %s: %w", pluginsDir, err)
+	}
+
+	return idx, nil
+}
+
+// buildNames indexes the maps by the map name.
+func (idx *mapIndex) buildNames() error {
+	idx.names = map[string][]string{}
+
+	for _, mount := range idx.mounts {
+		contentDir := mount.dir
+		err := filepath.WalkDir(contentDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == contentDir {
+					return filepath.SkipDir
+				}
+				return err
+			}
+
+			if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".umap") {
+				return nil
+			}
+
+			rel, err := filepath.Rel(contentDir, strings.TrimSuffix(path, filepath.Ext(path)))
+			if err != nil {
+				return err
+			}
+
+			name := strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(path)))
+			idx.names[name] = append(idx.names[name], packagePath(mount, rel))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find maps in %s: %w", contentDir, err)
+		}
+	}
+
+	for _, paths := range idx.names {
+		sort.Strings(paths)
+	}
+
+	return nil
+}
+
+// packagePath returns the package path of the map file relative to the content directory of the mount point.
+func packagePath(mount contentMount, rel string) string {
+	return "/" + mount.name + "/" + filepath.ToSlash(rel)
+}
+
+// resolve returns the package path of the map entry: a package path, a path relative to the project Content directory
+// or a map name.
+func (idx *mapIndex) resolve(entry string) (string, error) {
+	entry = strings.TrimSuffix(entry, ".umap")
+
+	// Package path, e.g. "/Game/Maps/Lobby" or "/Game/Maps/Lobby.Lobby"
+	if strings.HasPrefix(entry, "/") {
+		if dot := strings.LastIndex(entry, "."); dot > strings.LastIndex(entry, "/") {
+			entry = entry[:dot]
+		}
+
+		name, rel, _ := strings.Cut(strings.TrimPrefix(entry, "/"), "/")
+		mount, ok := idx.mounts[strings.ToLower(name)]
+		if !ok || rel == "" {
+			return "", fmt.Errorf("map %s not found", entry)
+		}
+
+		if _, err := os.Stat(filepath.Join(mount.dir, filepath.FromSlash(rel)+".umap")); err != nil {
+			return "", fmt.Errorf("map %s not found", entry)
+		}
+
+		return packagePath(mount, rel), nil
+	}
+
+	// Path relative to the project Content directory, e.g. "Maps/Lobby" or "Content/Maps/Lobby.umap"
+	if strings.ContainsAny(entry, `/\`) {
+		rel := filepath.ToSlash(entry)
+		rel = strings.TrimPrefix(rel, "Content/")
+		if _, err := os.Stat(filepath.Join(idx.mounts["game"].dir, filepath.FromSlash(rel)+".umap")); err != nil {
+			return "", fmt.Errorf("map %s not found", entry)
+		}
+		return packagePath(idx.mounts["game"], rel), nil
+	}
+
+	// Map name, e.g. "Lobby", must be unique in the project and the plugins
+	if idx.names == nil {
+		if err := idx.buildNames(); err != nil {
+			return "", err
+		}
+	}
+
+	paths := idx.names[strings.ToLower(entry)]
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("map %s not found", entry)
+	case 1:
+		return paths[0], nil
+	default:
+		return "", fmt.Errorf("map %s is ambiguous, use one of %s", entry, strings.Join(paths, ", "))
+	}
+}
+
+// readMapsToCook reads the maps listed in the project packaging settings.
+func readMapsToCook(projectDir string) ([]string, error) {
+	f, err := os.Open(IniFilePath(projectDir, "Game"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read packaging settings: %w", err)
+	}
+	defer f.Close()
+
+	var maps []string
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if name, ok := isIniSection(line); ok {
+			section = name
+			continue
+		}
+
+		if section != "/Script/UnrealEd.ProjectPackagingSettings" || !isIniKey(line, "MapsToCook") {
+			continue
+		}
+
+		// Array clear operations reset the list
+		if strings.HasPrefix(line, "!") {
+			maps = nil
+			continue
+		}
+
+		if m := mapsToCookRegexp.FindStringSubmatch(line); m != nil {
+			if strings.HasPrefix(line, "-") {
+				for i, p := range maps {
+					if p == m[1] {
+						maps = append(maps[:i], maps[i+1:]...)
+						break
+					}
+				}
+			} else {
+				maps = append(maps, m[1])
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read packaging settings: %w", err)
+	}
+
+	return maps, nil
+}
+
+// ResolveMaps resolves the map list of the release options to the map package paths, all the maps must exist. The list
+// is separated with commas or pluses and contains package paths ("/Game/Maps/Lobby", "/MyPlugin/Maps/Arena"), paths
+// relative to the Content directory ("Maps/Lobby") or unique map names ("Lobby"). Entries starting with "@" refer to the
+// named map lists, e.g. "@default". If the list is empty, the maps of the project packaging settings are used, they must
+// exist too.
+func ResolveMaps(projectDir string, list string, named map[string][]string) (*CookMaps, error) {
+	// Expand the entries and the named lists
+	var entries []string
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '+' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.HasPrefix(entry, "@") {
+			name := entry[1:]
+			maps, ok := named[name]
+			if !ok {
+				return nil, fmt.Errorf("invalid map list: unknown named map list %s", name)
+			}
+			entries = append(entries, maps...)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// The packaging settings apply if no maps are listed, their maps are checked the same way
+	result := &CookMaps{Source: MapsSourceRelease}
+	if len(entries) == 0 {
+		maps, err := readMapsToCook(projectDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(maps) == 0 {
+			return &CookMaps{Source: MapsSourceAll}, nil
+		}
+		entries = maps
+		result.Source = MapsSourcePackagingSettings
+	}
+
+	idx, err := newMapIndex(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var problems []string
+	for _, entry := range entries {
+		path, err := idx.resolve(entry)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		if !seen[strings.ToLower(path)] {
+			seen[strings.ToLower(path)] = true
+			result.Maps = append(result.Maps, path)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid map list: %s", strings.Join(problems, "; "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,237 @@
+package unreal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectModule is a code module of the project descriptor
+type ProjectModule struct {
+	Name              string   `json:"Name"`
+	Type              string   `json:"Type"`              // Module type, e.g. "Runtime" or "Editor"
+	LoadingPhase      string   `json:"LoadingPhase"`      // Module loading phase, e.g. "Default"
+	PlatformAllowList []string `json:"PlatformAllowList"` // Platforms the module is built for, all platforms if empty
+	PlatformDenyList  []string `json:"PlatformDenyList"`  // Platforms the module is not built for
+}
+
+// ProjectPlugin is a plugin reference of the project descriptor
+type ProjectPlugin struct {
+	Name              string   `json:"Name"`
+	Enabled           bool     `json:"Enabled"`
+	Optional          bool     `json:"Optional"`          // Optional plugins are not required to exist
+	MarketplaceURL    string   `json:"MarketplaceURL"`    // Marketplace page of the plugin installed to the engine
+	PlatformAllowList []string `json:"PlatformAllowList"` // Platforms the plugin is enabled for, all platforms if empty
+	PlatformDenyList  []string `json:"PlatformDenyList"`  // Platforms the plugin is disabled for
+
+	// Unreal Engine 4 names of the platform lists
+	WhitelistPlatforms []string `json:"WhitelistPlatforms"`
+	BlacklistPlatforms []string `json:"BlacklistPlatforms"`
+}
+
+// ProjectDescriptor is the Unreal Engine project descriptor (.uproject file)
+type ProjectDescriptor struct {
+	FileVersion       int             `json:"FileVersion"`
+	EngineAssociation string          `json:"EngineAssociation"` // Engine version, installation identifier or empty for the engine in a parent directory
+	Category          string          `json:"Category"`
+	Description       string          `json:"Description"`
+	Modules           []ProjectModule `json:"Modules"`
+	Plugins           []ProjectPlugin `json:"Plugins"`
+	TargetPlatforms   []string        `json:"TargetPlatforms"` // Platforms the project is packaged for, all platforms if empty
+}
+
+// ProjectCheck is the result of the project descriptor check, recorded in the release report
+type ProjectCheck struct {
+	Path              string   `json:"path"`              // Path to the project descriptor
+	EngineAssociation string   `json:"engineAssociation"` // Engine association of the project
+	Platform          string   `json:"platform"`          // Platform the project was checked for
+	Modules           []string `json:"modules"`           // Code modules of the project
+	Plugins           []string `json:"plugins"`           // Plugins enabled for the platform
+	MissingPlugins    []string `json:"missingPlugins"`    // Required plugins not found in the project or the engine
+	Problems          []string `json:"problems"`          // Problems found, the project can not be built if not empty
+}
+
+// ProjectDescriptorPath returns the path to the project descriptor file.
+func ProjectDescriptorPath(projectDir string, project string) string {
+	return filepath.Join(projectDir, project+".uproject")
+}
+
+// ReadProjectDescriptor reads and parses the project descriptor file.
+func ReadProjectDescriptor(path string) (*ProjectDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project descriptor: %w", err)
+	}
+
+	var d ProjectDescriptor
+	if err = json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid project descriptor %s: %w", path, err)
+	}
+
+	if d.FileVersion == 0 {
+		return nil, fmt.Errorf("invalid project descriptor %s: missing FileVersion", path)
+	}
+
+	return &d, nil
+}
+
+// EngineDir returns the engine directory of the editor executable, e.g. "X:/UnrealEngine/Engine" for
+// "X:/UnrealEngine/Engine/Binaries/Win64/UnrealEditor-Cmd.exe", or an empty string if the path is not in an engine.
+func EngineDir(editorPath string) string {
+	if editorPath == "" {
+		return ""
+	}
+
+	dir := filepath.Dir(filepath.Dir(filepath.Dir(editorPath)))
+	if !strings.EqualFold(filepath.Base(dir), "Engine") {
+		return ""
+	}
+
+	return dir
+}
+
+// normalizePlatform returns the platform name used by the project descriptors, e.g. "Windows" for "Win64" or
+// "WindowsNoEditor".
+func normalizePlatform(platform string) string {
+	for _, suffix := range []string{"NoEditor", "Client", "Server"} {
+		platform = strings.TrimSuffix(platform, suffix)
+	}
+
+	if strings.EqualFold(platform, "Win64") {
+		return "Windows"
+	}
+
+	return platform
+}
+
+// containsPlatform checks if the platform list contains the platform.
+func containsPlatform(platforms []string, platform string) bool {
+	platform = normalizePlatform(platform)
+	for _, p := range platforms {
+		if strings.EqualFold(normalizePlatform(p), platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsPlatform checks if the project is packaged for the platform.
+func (d *ProjectDescriptor) SupportsPlatform(platform string) bool {
+	return len(d.TargetPlatforms) == 0 || containsPlatform(d.TargetPlatforms, platform)
+}
+
+// EnabledFor checks if the plugin is enabled for the platform.
+func (p *ProjectPlugin) EnabledFor(platform string) bool {
+	if !p.Enabled {
+		return false
+	}
+
+	allow := append(p.PlatformAllowList, p.WhitelistPlatforms...)
+	if len(allow) > 0 && !containsPlatform(allow, platform) {
+		return false
+	}
+
+	return !containsPlatform(append(p.PlatformDenyList, p.BlacklistPlatforms...), platform)
+}
+
+// findPlugins returns the names of the plugin descriptors found in the plugin directories.
+func findPlugins(dirs ...string) (map[string]bool, error) {
+	plugins := map[string]bool{}
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == dir {
+					return filepath.SkipDir
+				}
+				return err
+			}
+
+			if entry.IsDir() {
+				// Plugins are not nested into their content and build directories
+				switch entry.Name() {
+				case "Binaries", "Content", "Intermediate", "Resources", "Saved", "Source":
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if strings.EqualFold(filepath.Ext(path), ".uplugin") {
+				plugins[strings.ToLower(strings.TrimSuffix(entry.Name(), filepath.Ext(path)))] = true
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find plugins in %s: %w", dir, err)
+		}
+	}
+
+	return plugins, nil
+}
+
+// CheckProject reads the project descriptor and checks it against the job platform: the project is packaged for the
+// platform and every required plugin exists in the project or the engine Plugins directory. The engine directory may
+// be empty, then only the project plugins are found. Returns the check result and an error if problems were found.
+func CheckProject(projectDir string, project string, platform string, engineDir string) (*ProjectCheck, error) {
+	check := &ProjectCheck{
+		Path:     ProjectDescriptorPath(projectDir, project),
+		Platform: platform,
+	}
+
+	d, err := ReadProjectDescriptor(check.Path)
+	if err != nil {
+		return check, err
+	}
+	check.EngineAssociation = d.EngineAssociation
+
+	// Check the target platform
+	if !d.SupportsPlatform(platform) {
+		check.Problems = append(check.Problems, fmt.Sprintf("platform %s is not in the project target platforms %s", platform, strings.Join(d.TargetPlatforms, ", ")))
+	}
+
+	// Collect the modules
+	for _, m := range d.Modules {
+		check.Modules = append(check.Modules, m.Name)
+	}
+
+	// Find the plugins available to the project
+	dirs := []string{filepath.Join(projectDir, "Plugins")}
+	if engineDir != "" {
+		dirs = append(dirs, filepath.Join(engineDir, "Plugins"))
+	}
+	available, err := findPlugins(dirs...)
+	if err != nil {
+		return check, err
+	}
+
+	// Check the required plugins exist
+	for i := range d.Plugins {
+		p := &d.Plugins[i]
+		if !p.EnabledFor(platform) {
+			continue
+		}
+
+		check.Plugins = append(check.Plugins, p.Name)
+
+		if !p.Optional && !available[strings.ToLower(p.Name)] {
+			check.MissingPlugins = append(check.MissingPlugins, p.Name)
+		}
+	}
+	sort.Strings(check.Plugins)
+	sort.Strings(check.MissingPlugins)
+
+	if len(check.MissingPlugins) > 0 {
+		check.Problems = append(check.Problems, fmt.Sprintf("missing plugins: %s", strings.Join(check.MissingPlugins, ", ")))
+	}
+
+	if len(check.Problems) > 0 {
+		return check, fmt.Errorf("invalid project %s: %s", check.Path, strings.Join(check.Problems, "; "))
+	}
+
+	return check, nil
+}
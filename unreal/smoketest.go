@@ -0,0 +1,187 @@
+package unreal
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"l7-cloud-builder/cmd"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/unreallog"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// smokeTestExcerptSize is the number of the last server log lines kept in the smoke test result
+const smokeTestExcerptSize = 30
+
+// SmokeTestResult is the result of the dedicated server smoke test, recorded in the release report
+type SmokeTestResult struct {
+	Passed    bool     `json:"passed"`
+	Server    string   `json:"server"`              // Path to the server binary or the launch script
+	Port      int      `json:"port"`                // Port the server was started on
+	Duration  string   `json:"duration"`            // Time until the server was ready or the test failed
+	ReadyLine string   `json:"readyLine,omitempty"` // Log line matching the ready pattern
+	Excerpt   []string `json:"excerpt"`             // Last server log lines
+	Error     string   `json:"error,omitempty"`     // Reason of the failure
+}
+
+// SmokeTestError is returned when the dedicated server smoke test fails
+type SmokeTestError struct {
+	Result  *SmokeTestResult   // Result of the smoke test
+	Summary *unreallog.Summary // Summary of the server log
+}
+
+// Error returns the error message with the last server log lines.
+func (e *SmokeTestError) Error() string {
+	message := fmt.Sprintf("server smoke test failed: %s", e.Result.Error)
+	if e.Summary != nil && e.Summary.HasErrors() {
+		message += ": " + e.Summary.Message(statusErrorCount)
+	}
+	if n := len(e.Result.Excerpt); n > 0 {
+		tail := e.Result.Excerpt
+		if n > 10 {
+			tail = tail[n-10:]
+		}
+		message += "; last log lines: " + strings.Join(tail, "; ")
+	}
+	return message
+}
+
+// FindServerBinary finds the dedicated server launch script or binary of the project in the staged build, e.g.
+// "LinuxServer/MetaverseServer.sh".
+func FindServerBinary(stagingDir string, project string) (string, error) {
+	candidates := []string{project + "Server.sh", project + "Server"}
+
+	var found []string
+	err := filepath.WalkDir(stagingDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Skip the content directories
+			if entry.Name() == "Content" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, c := range candidates {
+			if entry.Name() == c {
+				found = append(found, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find server binary: %w", err)
+	}
+
+	// Prefer the launch script, it sets up the working directory and the arguments
+	for _, c := range candidates {
+		for _, path := range found {
+			if filepath.Base(path) == c {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("failed to find server binary %s in %s", candidates[0], stagingDir)
+}
+
+// freePort returns a port free for both TCP and UDP, the game servers listen on UDP and the beacons on TCP.
+func freePort() (int, error) {
+	for i := 0; i < 10; i++ {
+		udp, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			return 0, err
+		}
+		port := udp.LocalAddr().(*net.UDPAddr).Port
+
+		tcp, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		_ = udp.Close()
+		if err == nil {
+			_ = tcp.Close()
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to find a free port")
+}
+
+// SmokeTestServer launches the dedicated server with -log -nullrhi on a random port and waits for a log line matching
+// the ready pattern within the timeout, then shuts the server down. Returns the result and a SmokeTestError if the
+// server exits, or does not get ready in time.
+func SmokeTestServer(ctx context.Context, server string, readyPattern string, timeout time.Duration, args []string) (*SmokeTestResult, error) {
+	result := &SmokeTestResult{Server: server}
+
+	ready, err := regexp.Compile(readyPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smoke test ready pattern: %w", err)
+	}
+
+	if result.Port, err = freePort(); err != nil {
+		return nil, err
+	}
+
+	// Keep the last log lines and stop the server when ready
+	parser := unreallog.NewParser()
+	testCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	onOutput := func(line string) {
+		parser.Line(line)
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.Excerpt = append(result.Excerpt, line)
+		if len(result.Excerpt) > smokeTestExcerptSize {
+			result.Excerpt = result.Excerpt[len(result.Excerpt)-smokeTestExcerptSize:]
+		}
+		if result.ReadyLine == "" && ready.MatchString(line) {
+			result.ReadyLine = line
+			cancel()
+		}
+	}
+
+	var c = &cmd.Cmd{
+		Command:     server,
+		CommandLine: "{args...} -log -nullrhi -unattended -port={port}",
+		WorkingDir:  filepath.Dir(server),
+		Env:         config.Unreal.Environment,
+		OnOutput:    onOutput,
+		Placeholders: map[string]string{
+			"port": strconv.Itoa(result.Port),
+		},
+		Lists: map[string][]string{
+			"args": args,
+		},
+	}
+
+	started := time.Now()
+	runErr := c.Run(testCtx)
+	result.Duration = time.Since(started).Round(time.Second).String()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The server is stopped by cancelling the context once ready
+	switch {
+	case result.ReadyLine != "":
+		result.Passed = true
+		return result, nil
+	case ctx.Err() != nil:
+		return result, ctx.Err()
+	case testCtx.Err() != nil:
+		result.Error = fmt.Sprintf("server is not ready within %s", timeout)
+	case runErr != nil:
+		result.Error = fmt.Sprintf("server exited before ready: %v", runErr)
+	default:
+		result.Error = "server exited before ready"
+	}
+
+	return result, &SmokeTestError{Result: result, Summary: parser.Summary()}
+}
@@ -5,35 +5,107 @@ import (
 	"fmt"
 	"l7-cloud-builder/cmd"
 	"l7-cloud-builder/config"
+	"l7-cloud-builder/logger"
+	"l7-cloud-builder/unreallog"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 )
 
 func GetStagingDir(projectDir string) string {
 	return filepath.Join(projectDir, "Saved", "StagedBuilds")
 }
 
-func RunAutomationTool(ctx context.Context, workdir string, command string, cmdline string, placeholders map[string]string) error {
+// statusErrorCount is the number of top errors included into the Automation Tool error message
+const statusErrorCount = 5
+
+// AutomationToolError is returned when the Unreal Automation Tool fails, contains the summary of the parsed output and logs
+type AutomationToolError struct {
+	Summary *unreallog.Summary // Summary of the command output and the logs written while the command was running
+	Err     error              // Error returned by the command
+}
+
+// Error returns the error message with the top errors from the summary.
+func (e *AutomationToolError) Error() string {
+	if e.Summary != nil && (e.Summary.HasErrors() || e.Summary.ExitCode != nil) {
+		return fmt.Sprintf("failed to run Unreal Automation Tool: %s", e.Summary.Message(statusErrorCount))
+	}
+	return fmt.Sprintf("failed to run Unreal Automation Tool: %v", e.Err)
+}
+
+// Unwrap returns the error returned by the command.
+func (e *AutomationToolError) Unwrap() error {
+	return e.Err
+}
+
+// RunAutomationTool runs the Unreal Automation Tool, the output is parsed while running. If the command fails, the
+// project logs written while the command was running are parsed too and the error is returned as AutomationToolError.
+func RunAutomationTool(ctx context.Context, workdir string, command string, cmdline string, placeholders map[string]string, lists map[string][]string) error {
+	parser := unreallog.NewParser()
+	started := time.Now()
+
 	var uat = &cmd.Cmd{
-		Command:      command,
-		CommandLine:  cmdline,
-		WorkingDir:   workdir,
-		Placeholders: placeholders,
+		Command:           command,
+		CommandLine:       cmdline,
+		WorkingDir:        workdir,
+		Placeholders:      placeholders,
+		Lists:             lists,
+		Env:               config.Unreal.Environment,
+		Timeout:           config.Unreal.Limits.Timeout,
+		Nice:              config.Unreal.Limits.Nice,
+		MemoryLimit:       config.Unreal.Limits.MemoryLimit,
+		InactivityTimeout: config.Unreal.Limits.InactivityTimeout,
+		OnOutput:          parser.Line,
 	}
 
 	if err := uat.Run(ctx); err != nil {
-		return fmt.Errorf("failed to run Unreal Automation Tool: %w", err)
+		// Parse the logs written by the Editor while the command was running, they contain the details not forwarded to the output
+		if _, err1 := parser.ReadDir(unreallog.ProjectLogsDir(workdir), started); err1 != nil && !os.IsNotExist(err1) {
+			logger.Logger.Warningf("failed to parse project logs: %v", err1)
+		}
+
+		return &AutomationToolError{Summary: parser.Summary(), Err: err}
 	}
 
 	return nil
 }
 
-// SwitchProjectEngineVersion switches the engine version for the project
+// SwitchProjectEngineVersion switches the engine version for the project and verifies the project descriptor
+// EngineAssociation matches the version after switching, returns the new engine association. The Unreal Version Selector
+// is used on Windows, on other platforms the engine registry file and the project descriptor are updated directly.
 // workdir: the project directory
 // version: the engine version to switch to (marketplace version or source version directory)
-func SwitchProjectEngineVersion(ctx context.Context, workdir string, project string, version string) error {
+func SwitchProjectEngineVersion(ctx context.Context, workdir string, project string, version string) (string, error) {
 	// Get path to the project descriptor file
-	projectDescriptorPath := filepath.Join(workdir, project+".uproject")
+	projectDescriptorPath := ProjectDescriptorPath(workdir, project)
+
+	if runtime.GOOS == "windows" {
+		if err := runVersionSelector(ctx, workdir, projectDescriptorPath, version); err != nil {
+			return "", err
+		}
+	} else {
+		if err := switchEngineAssociation(projectDescriptorPath, version); err != nil {
+			return "", fmt.Errorf("failed to switch engine version for the project %s: %w", projectDescriptorPath, err)
+		}
+	}
+
+	// The version selector may exit successfully without changing the descriptor, verify the result
+	d, err := ReadProjectDescriptor(projectDescriptorPath)
+	if err != nil {
+		return "", err
+	}
 
+	if !engineAssociationMatches(workdir, d.EngineAssociation, version) {
+		return d.EngineAssociation, fmt.Errorf("invalid project %s: engine association %q does not match engine version %s after switching", projectDescriptorPath, d.EngineAssociation, version)
+	}
+
+	return d.EngineAssociation, nil
+}
+
+// runVersionSelector switches the engine version for the project with the Unreal Version Selector.
+func runVersionSelector(ctx context.Context, workdir string, projectDescriptorPath string, version string) error {
 	// Select existing engine version selector tool from code or marketplace config
 	var versionSelectorPath string
 	if config.Unreal.Code.VersionSelectorPath != "" {
@@ -58,3 +130,35 @@ func SwitchProjectEngineVersion(ctx context.Context, workdir string, project str
 
 	return nil
 }
+
+// engineAssociationMatches checks if the project engine association refers to the engine version: the same version
+// identifier, the engine in a parent directory of the project or a registered installation in the version directory.
+func engineAssociationMatches(projectDir string, association string, version string) bool {
+	if strings.EqualFold(strings.Trim(association, "{}"), strings.Trim(version, "{}")) {
+		return true
+	}
+
+	// The version is an identifier, not a source engine directory
+	if info, err := os.Stat(version); err != nil || !info.IsDir() {
+		return false
+	}
+
+	// Empty association uses the engine in a parent directory of the project
+	if association == "" {
+		rel, err := filepath.Rel(version, projectDir)
+		return err == nil && !strings.HasPrefix(rel, "..")
+	}
+
+	// Source engine builds are registered with an identifier
+	dir, ok := registeredEngineDir(association)
+	return ok && samePath(dir, version)
+}
+
+// samePath checks if the paths refer to the same directory.
+func samePath(a string, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
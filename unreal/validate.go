@@ -0,0 +1,177 @@
+package unreal
+
+import (
+	"context"
+	"fmt"
+	"l7-cloud-builder/cmd"
+	"l7-cloud-builder/config"
+	"l7-cloud-builder/unreallog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// validationFindingsListed is the number of the validation errors listed in the error message
+const validationFindingsListed = 10
+
+// validationCategories are the log categories of the data validation and the asset checks, their findings are reported
+// even without an asset path
+var validationCategories = map[string]bool{
+	"LogContentValidation": true,
+	"LogDataValidation":    true,
+	"LogAssetCheck":        true,
+	"AssetCheck":           true,
+}
+
+// ValidationFinding is an error or warning reported for the validated content
+type ValidationFinding struct {
+	Asset    string             `json:"asset,omitempty"`    // Package path of the asset, e.g. "/MyPackage/Maps/Arena"
+	Severity unreallog.Severity `json:"severity"`           // Severity of the finding, e.g. "error" or "warning"
+	Category string             `json:"category,omitempty"` // Log category, e.g. "LogContentValidation"
+	Message  string             `json:"message"`            // Message of the finding
+}
+
+// ValidationResult is the result of the content validation
+type ValidationResult struct {
+	Mount    string              `json:"mount"` // Content mount point validated, e.g. "/MyPackage/"
+	Errors   int                 `json:"errors"`
+	Warnings int                 `json:"warnings"`
+	Findings []ValidationFinding `json:"findings"`
+}
+
+// Message returns the error and warning counts reported in the job status, e.g. "validation: 2 errors, 5 warnings".
+func (r *ValidationResult) Message() string {
+	return fmt.Sprintf("validation: %d errors, %d warnings", r.Errors, r.Warnings)
+}
+
+// ValidationError is returned when the content validation reports errors
+type ValidationError struct {
+	Result *ValidationResult
+}
+
+// Error returns the error message with the counts and the first validation errors.
+func (e *ValidationError) Error() string {
+	var errors []string
+	for _, f := range e.Result.Findings {
+		if f.Severity == unreallog.SeverityWarning {
+			continue
+		}
+		if len(errors) == validationFindingsListed {
+			errors = append(errors, "...")
+			break
+		}
+		if f.Asset != "" && !strings.Contains(f.Message, f.Asset) {
+			errors = append(errors, f.Asset+": "+f.Message)
+		} else {
+			errors = append(errors, f.Message)
+		}
+	}
+
+	message := fmt.Sprintf("content validation failed, %s", e.Result.Message())
+	if len(errors) > 0 {
+		message += ": " + strings.Join(errors, "; ")
+	}
+	return message
+}
+
+// assetPackagePath returns the package path of the asset object path, e.g. "/Game/Maps/Arena.Arena" -> "/Game/Maps/Arena".
+func assetPackagePath(asset string) string {
+	if i := strings.LastIndex(asset, "."); i > strings.LastIndex(asset, "/") {
+		return asset[:i]
+	}
+	return asset
+}
+
+// PackageContentDir returns the content directory of the UGC package plugin in the project, e.g.
+// "Plugins/MyPackage/Content".
+func PackageContentDir(projectDir string, name string) string {
+	return filepath.Join(projectDir, "Plugins", name, "Content")
+}
+
+// RunContentValidation runs the data validation commandlet with the Editor, e.g. "DataValidation", and collects the errors
+// and warnings reported for the assets of the content mount, e.g. "/MyPackage/", and the ones without an asset reported by
+// the validation log categories.
+// The commandlet exits with an error code when the validation fails, so the findings are collected regardless of the
+// exit code. Returns the result and a ValidationError if any errors are found.
+func RunContentValidation(ctx context.Context, editorPath string, projectDir string, project string, commandlet string, mount string, args []string) (*ValidationResult, error) {
+	result := &ValidationResult{Mount: mount}
+
+	var mu sync.Mutex
+	parser := unreallog.NewParser()
+	onOutput := func(line string) {
+		parser.Line(line)
+
+		issue, ok := unreallog.ParseIssue(line)
+		if !ok {
+			return
+		}
+
+		// The findings of the assets outside the package are dropped, the validation category findings without an asset
+		// are kept
+		asset := assetPackagePath(issue.Asset)
+		if asset != "" && !strings.HasPrefix(asset+"/", mount) {
+			return
+		}
+		if asset == "" && !validationCategories[issue.Category] {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if issue.Severity == unreallog.SeverityWarning {
+			result.Warnings++
+		} else {
+			result.Errors++
+		}
+		result.Findings = append(result.Findings, ValidationFinding{Asset: asset, Severity: issue.Severity, Category: issue.Category, Message: issue.Message})
+	}
+
+	var editor = &cmd.Cmd{
+		Command:     editorPath,
+		CommandLine: "{project} -run={commandlet} {args...} -unattended -nullrhi -nopause -nosplash -buildmachine -stdout -FullStdOutLogOutput",
+		WorkingDir:  projectDir,
+		Placeholders: map[string]string{
+			"project":    ProjectDescriptorPath(projectDir, project),
+			"commandlet": commandlet,
+		},
+		Lists: map[string][]string{
+			"args": args,
+		},
+		Env:               config.Unreal.Environment,
+		Timeout:           config.Unreal.Limits.Timeout,
+		Nice:              config.Unreal.Limits.Nice,
+		MemoryLimit:       config.Unreal.Limits.MemoryLimit,
+		InactivityTimeout: config.Unreal.Limits.InactivityTimeout,
+		OnOutput:          onOutput,
+	}
+
+	runErr := editor.Run(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if result.Errors > 0 {
+		return result, &ValidationError{Result: result}
+	}
+
+	// The commandlet failed without reporting the content errors, e.g. crashed or failed to start
+	if runErr != nil {
+		return nil, fmt.Errorf("failed to run content validation: %w", &AutomationToolError{Summary: parser.Summary(), Err: runErr})
+	}
+
+	return result, nil
+}
+
+// CheckPackageContent checks the UGC package plugin content directory exists in the project.
+func CheckPackageContent(projectDir string, name string) error {
+	dir := PackageContentDir(projectDir, name)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("invalid package %s: content directory %s not found", name, dir)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package unreallog
+
+import (
+	"bufio"
+	"io"
+	"l7-cloud-builder/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Parse parses the log from the reader.
+func Parse(r io.Reader) (*Summary, error) {
+	p := NewParser()
+	if err := p.Read(r); err != nil {
+		return nil, err
+	}
+	return p.Summary(), nil
+}
+
+// ParseFile parses the log file.
+func ParseFile(path string) (*Summary, error) {
+	p := NewParser()
+	if err := p.ReadFile(path); err != nil {
+		return nil, err
+	}
+	return p.Summary(), nil
+}
+
+// Read parses all the lines from the reader.
+func (p *Parser) Read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			p.Line(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFile parses all the lines of the log file.
+func (p *Parser) ReadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Logger.Errorf("failed to close file: %v", err)
+		}
+	}(file)
+
+	return p.Read(file)
+}
+
+// ReadDir parses the .log and .txt files in the directory modified after the time, e.g. the project Saved/Logs or the
+// Automation Tool logs directory. Files are parsed in the order of modification. Returns the list of parsed files.
+func (p *Parser) ReadDir(dir string, since time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+
+	var files []logFile
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".log" && ext != ".txt") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		files = append(files, logFile{path: filepath.Join(dir, e.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var parsed []string
+	for _, f := range files {
+		if err = p.ReadFile(f.path); err != nil {
+			return parsed, err
+		}
+		parsed = append(parsed, f.path)
+	}
+
+	return parsed, nil
+}
+
+// ProjectLogsDir returns the directory containing the project logs.
+func ProjectLogsDir(projectDir string) string {
+	return filepath.Join(projectDir, "Saved", "Logs")
+}
@@ -0,0 +1,372 @@
+package unreallog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxIssues is the maximum number of issues kept per kind, the rest are counted only
+const maxIssues = 500
+
+// maxCallstack is the maximum number of callstack lines kept for a crash
+const maxCallstack = 64
+
+// IssueKind is a kind of issue found in the log
+type IssueKind string
+
+const (
+	// IssueKindCompile is a compiler or linker error or warning
+	IssueKindCompile IssueKind = "compile"
+	// IssueKindCook is an error or warning reported while cooking
+	IssueKindCook IssueKind = "cook"
+	// IssueKindInit is a LogInit error, e.g. a missing module or plugin
+	IssueKindInit IssueKind = "init"
+	// IssueKindLog is any other log category error
+	IssueKindLog IssueKind = "log"
+)
+
+// Severity is a severity of an issue
+type Severity string
+
+const (
+	// SeverityWarning is a warning severity
+	SeverityWarning Severity = "warning"
+	// SeverityError is an error severity
+	SeverityError Severity = "error"
+	// SeverityFatal is a fatal error severity
+	SeverityFatal Severity = "fatal"
+)
+
+// Issue is an error or warning found in the log
+type Issue struct {
+	Kind     IssueKind `json:"kind"`               // Kind of the issue
+	Severity Severity  `json:"severity"`           // Severity of the issue
+	File     string    `json:"file,omitempty"`     // Source file of a compile issue
+	Line     int       `json:"line,omitempty"`     // Source line of a compile issue
+	Column   int       `json:"column,omitempty"`   // Source column of a compile issue
+	Code     string    `json:"code,omitempty"`     // Compiler or linker error code, e.g. C2065 or LNK2019
+	Category string    `json:"category,omitempty"` // Log category, e.g. LogCook
+	Asset    string    `json:"asset,omitempty"`    // Asset path of a cook issue, e.g. /Game/Maps/Main
+	Message  string    `json:"message"`            // Message of the issue
+}
+
+// String returns the issue formatted as a single line.
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(&b, "(%d)", i.Line)
+		}
+		b.WriteString(": ")
+	} else if i.Category != "" {
+		b.WriteString(i.Category)
+		b.WriteString(": ")
+	}
+	b.WriteString(string(i.Severity))
+	if i.Code != "" {
+		b.WriteString(" ")
+		b.WriteString(i.Code)
+	}
+	b.WriteString(": ")
+	if i.Asset != "" && !strings.Contains(i.Message, i.Asset) {
+		b.WriteString(i.Asset)
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// Crash is a crash found in the log
+type Crash struct {
+	Message   string   `json:"message"`   // First line of the crash report, e.g. the assertion message
+	Callstack []string `json:"callstack"` // Callstack lines
+}
+
+// Summary is a structured summary of an Unreal Automation Tool, Unreal Build Tool or Editor log
+type Summary struct {
+	CompileErrors   []Issue `json:"compileErrors"`   // Compiler and linker errors
+	CompileWarnings []Issue `json:"compileWarnings"` // Compiler warnings
+	CookErrors      []Issue `json:"cookErrors"`      // Errors reported while cooking
+	CookWarnings    []Issue `json:"cookWarnings"`    // Warnings reported while cooking
+	InitErrors      []Issue `json:"initErrors"`      // LogInit errors
+	OtherErrors     []Issue `json:"otherErrors"`     // Errors of the other log categories
+	Crash           *Crash  `json:"crash,omitempty"` // Crash, if the process crashed
+	ExitCode        *int    `json:"exitCode"`        // Final Automation Tool exit code, if reported
+	Dropped         int     `json:"dropped"`         // Number of issues dropped after reaching the limit
+}
+
+// Errors returns all the errors ordered by relevance: crash, compile, init, cook and other errors.
+func (s *Summary) Errors() []Issue {
+	var errors []Issue
+	if s.Crash != nil {
+		errors = append(errors, Issue{Kind: IssueKindLog, Severity: SeverityFatal, Message: s.Crash.Message})
+	}
+	errors = append(errors, s.CompileErrors...)
+	errors = append(errors, s.InitErrors...)
+	errors = append(errors, s.CookErrors...)
+	errors = append(errors, s.OtherErrors...)
+	return errors
+}
+
+// HasErrors checks if the summary contains any errors.
+func (s *Summary) HasErrors() bool {
+	return len(s.Errors()) > 0
+}
+
+// Message returns a short description of the result with up to n top errors, used for the job status.
+func (s *Summary) Message(n int) string {
+	var parts []string
+	if s.ExitCode != nil {
+		parts = append(parts, fmt.Sprintf("AutomationTool exited with code %d", *s.ExitCode))
+	}
+
+	var counts []string
+	for _, c := range []struct {
+		name  string
+		count int
+	}{
+		{"compile errors", len(s.CompileErrors)},
+		{"init errors", len(s.InitErrors)},
+		{"cook errors", len(s.CookErrors)},
+		{"other errors", len(s.OtherErrors)},
+	} {
+		if c.count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.count, c.name))
+		}
+	}
+	if s.Crash != nil {
+		counts = append([]string{"crashed"}, counts...)
+	}
+	if len(counts) > 0 {
+		parts = append(parts, strings.Join(counts, ", "))
+	}
+
+	message := strings.Join(parts, "; ")
+
+	errors := s.Errors()
+	if len(errors) > n {
+		errors = errors[:n]
+	}
+	for _, e := range errors {
+		message += "\n- " + e.String()
+	}
+
+	return strings.TrimSpace(message)
+}
+
+var (
+	// prefixRegex matches the timestamp and frame prefix of the Unreal log lines, e.g. "[2023.03.27-12.00.00:000][  0]"
+	prefixRegex = regexp.MustCompile(`^\[[^\]]*\]\[\s*\d+\]`)
+	// uatHelperRegex matches the prefix of the Editor output forwarded by UAT, e.g. "UATHelper: Packaging (Windows): "
+	uatHelperRegex = regexp.MustCompile(`^UATHelper: [^:]+: `)
+
+	// msvcErrorRegex matches MSVC errors and warnings, e.g. "D:\Project\Source\A.cpp(12,5): error C2065: 'x': undeclared identifier"
+	msvcErrorRegex = regexp.MustCompile(`^\s*(.+?)\((\d+)(?:,(\d+))?\)\s*:\s*(fatal error|error|warning)\s*([A-Z]+\d+)?\s*:\s*(.*)$`)
+	// clangErrorRegex matches Clang errors and warnings, e.g. "/project/Source/A.cpp:12:5: error: use of undeclared identifier 'x'"
+	clangErrorRegex = regexp.MustCompile(`^\s*(.+?):(\d+):(?:(\d+):)?\s*(fatal error|error|warning):\s*(.*)$`)
+	// linkerErrorRegex matches linker errors without a source file, e.g. "A.obj : error LNK2019: unresolved external symbol"
+	linkerErrorRegex = regexp.MustCompile(`(?:^|\s)(fatal error|error)\s+(LNK\d+):\s*(.*)$`)
+
+	// categoryRegex matches log category errors and warnings, e.g. "LogCook: Error: ..."
+	categoryRegex = regexp.MustCompile(`(?:^|\s)(Log\w+):\s*(Error|Warning|Fatal error|Fatal):\s*(.*)$`)
+	// assetRegex matches asset package paths, e.g. "/Game/Maps/Main" or "../Content/Maps/Main.umap"
+	assetRegex = regexp.MustCompile(`(/(?:Game|Engine|[A-Z][A-Za-z0-9_]*)/[A-Za-z0-9_\-/.]+|[^\s'"]+\.(?:uasset|umap))`)
+
+	// cookStartRegex and cookEndRegex match the start and end of the cook command
+	cookStartRegex = regexp.MustCompile(`COOK COMMAND STARTED|Running: .*-run=cook`)
+	cookEndRegex   = regexp.MustCompile(`COOK COMMAND COMPLETED`)
+
+	// crashStartRegex matches the first line of a crash report
+	crashStartRegex = regexp.MustCompile(`=== Critical error: ===|Fatal error: |appError called: |Assertion failed: |Unhandled Exception: |Caught signal`)
+	// callstackRegex matches the callstack lines of a crash report
+	callstackRegex = regexp.MustCompile(`\[Callstack\]|^\s*0x[0-9a-fA-F]+ |^\s*#\d+ `)
+
+	// exitCodeRegex matches the final Automation Tool exit code
+	exitCodeRegex = regexp.MustCompile(`AutomationTool exit(?:ed with code|ing with ExitCode=)\s*(-?\d+)`)
+)
+
+// Parser parses the log line by line, can be used to parse the command output while the command is running
+type Parser struct {
+	mu      sync.Mutex
+	summary Summary
+	cooking bool
+	inCrash bool
+	seen    map[string]bool
+}
+
+// NewParser creates a new log parser.
+func NewParser() *Parser {
+	return &Parser{seen: map[string]bool{}}
+}
+
+// Summary returns the summary of the parsed lines.
+func (p *Parser) Summary() *Summary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.summary
+	return &s
+}
+
+// Line parses the log line, safe for concurrent use.
+func (p *Parser) Line(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	line = strings.TrimRight(line, "\r\n")
+	line = prefixRegex.ReplaceAllString(line, "")
+	line = uatHelperRegex.ReplaceAllString(line, "")
+
+	if m := exitCodeRegex.FindStringSubmatch(line); m != nil {
+		if code, err := strconv.Atoi(m[1]); err == nil {
+			p.summary.ExitCode = &code
+		}
+		return
+	}
+
+	// Track the cook phase to attribute the log errors to the cook
+	if cookEndRegex.MatchString(line) {
+		p.cooking = false
+	} else if cookStartRegex.MatchString(line) {
+		p.cooking = true
+	}
+
+	// Collect the crash callstack
+	if crashStartRegex.MatchString(line) {
+		message := strings.TrimSpace(crashMessage(line))
+		if p.summary.Crash == nil {
+			p.summary.Crash = &Crash{Message: message}
+		} else if strings.Contains(p.summary.Crash.Message, "=== Critical error: ===") {
+			// The header line carries no information, use the following line with the error
+			p.summary.Crash.Message = message
+		}
+		p.inCrash = true
+	} else if p.inCrash && callstackRegex.MatchString(line) {
+		if len(p.summary.Crash.Callstack) < maxCallstack {
+			p.summary.Crash.Callstack = append(p.summary.Crash.Callstack, strings.TrimSpace(crashMessage(line)))
+		}
+		return
+	} else if p.inCrash && strings.TrimSpace(crashMessage(line)) != "" {
+		// The crash report ends with the first line that is not a callstack line, the empty error lines are part of it
+		p.inCrash = false
+	}
+
+	if issue, ok := parseCompileIssue(line); ok {
+		if issue.Severity == SeverityWarning {
+			p.add(&p.summary.CompileWarnings, issue)
+		} else {
+			p.add(&p.summary.CompileErrors, issue)
+		}
+		return
+	}
+
+	if m := categoryRegex.FindStringSubmatch(line); m != nil {
+		issue := Issue{Kind: IssueKindLog, Category: m[1], Severity: SeverityError, Message: strings.TrimSpace(m[3])}
+		if m[2] == "Warning" {
+			issue.Severity = SeverityWarning
+		} else if strings.HasPrefix(m[2], "Fatal") {
+			issue.Severity = SeverityFatal
+		}
+
+		switch {
+		case issue.Category == "LogInit" && issue.Severity != SeverityWarning:
+			issue.Kind = IssueKindInit
+			p.add(&p.summary.InitErrors, issue)
+		case p.cooking || issue.Category == "LogCook" || issue.Category == "LogSavePackage":
+			issue.Kind = IssueKindCook
+			if a := assetRegex.FindString(issue.Message); a != "" {
+				issue.Asset = strings.TrimRight(a, ".")
+			}
+			if issue.Severity == SeverityWarning {
+				p.add(&p.summary.CookWarnings, issue)
+			} else {
+				p.add(&p.summary.CookErrors, issue)
+			}
+		case issue.Severity != SeverityWarning && !p.inCrash:
+			p.add(&p.summary.OtherErrors, issue)
+		}
+	}
+}
+
+// add adds the issue to the list unless it is a duplicate or the list is full.
+func (p *Parser) add(list *[]Issue, issue Issue) {
+	key := issue.String()
+	if p.seen[key] {
+		return
+	}
+	p.seen[key] = true
+
+	if len(*list) >= maxIssues {
+		p.summary.Dropped++
+		return
+	}
+	*list = append(*list, issue)
+}
+
+// parseCompileIssue parses compiler and linker errors and warnings.
+func parseCompileIssue(line string) (Issue, bool) {
+	severity := func(s string) Severity {
+		switch s {
+		case "warning":
+			return SeverityWarning
+		case "fatal error":
+			return SeverityFatal
+		}
+		return SeverityError
+	}
+
+	if m := msvcErrorRegex.FindStringSubmatch(line); m != nil {
+		l, _ := strconv.Atoi(m[2])
+		c, _ := strconv.Atoi(m[3])
+		return Issue{Kind: IssueKindCompile, Severity: severity(m[4]), File: strings.TrimSpace(m[1]), Line: l, Column: c, Code: m[5], Message: strings.TrimSpace(m[6])}, true
+	}
+
+	if m := clangErrorRegex.FindStringSubmatch(line); m != nil {
+		l, _ := strconv.Atoi(m[2])
+		c, _ := strconv.Atoi(m[3])
+		return Issue{Kind: IssueKindCompile, Severity: severity(m[4]), File: strings.TrimSpace(m[1]), Line: l, Column: c, Message: strings.TrimSpace(m[5])}, true
+	}
+
+	if m := linkerErrorRegex.FindStringSubmatch(line); m != nil {
+		return Issue{Kind: IssueKindCompile, Severity: severity(m[1]), Code: m[2], Message: strings.TrimSpace(m[3])}, true
+	}
+
+	return Issue{}, false
+}
+
+// crashMessage strips the log category prefix from the crash report line.
+func crashMessage(line string) string {
+	if m := categoryRegex.FindStringSubmatch(line); m != nil {
+		return m[3]
+	}
+	return line
+}
+
+// ParseIssue parses a single log category error or warning line, e.g. "LogContentValidation: Error: /Game/A: message",
+// the asset path mentioned in the message is set as the issue asset.
+func ParseIssue(line string) (Issue, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	line = prefixRegex.ReplaceAllString(line, "")
+	line = uatHelperRegex.ReplaceAllString(line, "")
+
+	m := categoryRegex.FindStringSubmatch(line)
+	if m == nil {
+		return Issue{}, false
+	}
+
+	issue := Issue{Kind: IssueKindLog, Category: m[1], Severity: SeverityError, Message: strings.TrimSpace(m[3])}
+	if m[2] == "Warning" {
+		issue.Severity = SeverityWarning
+	} else if strings.HasPrefix(m[2], "Fatal") {
+		issue.Severity = SeverityFatal
+	}
+	if a := assetRegex.FindString(issue.Message); a != "" {
+		issue.Asset = strings.TrimRight(a, ".")
+	}
+
+	return issue, true
+}
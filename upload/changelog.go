@@ -0,0 +1,29 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"net/url"
+	"path/filepath"
+)
+
+// ReleaseChangelog uploads the release changelog file to the cloud for storage, the markdown and the JSON changelogs are
+// supported
+func ReleaseChangelog(ctx context.Context, releaseId uuid.UUID, target, platform, path string, originalPath string, params map[string]string) error {
+	if releaseId.IsNil() {
+		return fmt.Errorf("invalid release id")
+	}
+
+	var (
+		fileType = "release-changelog"
+		fileMime = url.QueryEscape("text/markdown")
+	)
+
+	if filepath.Ext(path) == ".json" {
+		fileType = "release-changelog-json"
+		fileMime = url.QueryEscape("application/json")
+	}
+
+	return uploadEntityFile(ctx, releaseId, fileType, fileMime, target, platform, path, originalPath, params)
+}
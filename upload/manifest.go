@@ -0,0 +1,22 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"net/url"
+)
+
+// ReleaseManifest uploads the release manifest file to the cloud for storage
+func ReleaseManifest(ctx context.Context, releaseId uuid.UUID, target, platform, path string, originalPath string, params map[string]string) error {
+	if releaseId.IsNil() {
+		return fmt.Errorf("invalid release id")
+	}
+
+	var (
+		fileType = "release-manifest"
+		fileMime = url.QueryEscape("application/json")
+	)
+
+	return uploadEntityFile(ctx, releaseId, fileType, fileMime, target, platform, path, originalPath, params)
+}
@@ -0,0 +1,28 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"net/url"
+	"path/filepath"
+)
+
+// TestReport uploads the automation test report file to the cloud for storage as a job artifact, the JSON and the JUnit
+// reports are supported
+func TestReport(ctx context.Context, jobId uuid.UUID, target, platform, path string, originalPath string, params map[string]string) error {
+	if jobId.IsNil() {
+		return fmt.Errorf("invalid job id")
+	}
+
+	var (
+		fileType = "test-report"
+		fileMime = url.QueryEscape("application/json")
+	)
+
+	if filepath.Ext(path) == ".xml" {
+		fileMime = url.QueryEscape("application/xml")
+	}
+
+	return uploadEntityFile(ctx, jobId, fileType, fileMime, target, platform, path, originalPath, params)
+}
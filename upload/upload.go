@@ -108,7 +108,11 @@ func uploadFileInChunks(file *os.File, pipeWriter *io.PipeWriter, openingHeader,
 // - Send an HTTP PUT request with the pipe reader as the request body
 // - Handle the response and return any errors
 func uploadEntityFile(ctx context.Context, entityId uuid.UUID, fileType, fileMime, target, platform, path, originalPath string, params map[string]string) error {
-	const chunkSize = 100 * 1024 * 1024 // 100MiB
+	// Get the chunk size from the shared configuration
+	chunkSize := config.SharedConfiguration().Upload.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultSharedConfiguration().Upload.ChunkSize
+	}
 
 	// Validate the entity id
 	if entityId.IsNil() {
@@ -0,0 +1,154 @@
+// Summary: Workspace subcommands.
+// Description: This file is used to inspect, sync and clean the source directories of the node without running jobs.
+
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"l7-cloud-builder/git"
+	"l7-cloud-builder/processing"
+	"text/tabwriter"
+)
+
+// formatWorkspace formats the dirty files found by the clean, e.g. "clean" or "reset 3 modified or untracked files".
+func formatWorkspace(w *git.Workspace) string {
+	if w.DirtyCount == 0 {
+		return "clean"
+	}
+	return fmt.Sprintf("reset %d modified or untracked files", w.DirtyCount)
+}
+
+// newWorkspaceCmd creates the workspace command with the status, sync and clean subcommands.
+func newWorkspaceCmd() *cobra.Command {
+	workspaceCmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Inspect, sync and clean the source directories of the node",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Load the source directories the same way as the job processing
+			loadSourceConfig()
+		},
+	}
+
+	//region Status
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the checked out ref, the dirty files and the LFS objects of every configured source directory",
+		Args:  cobra.NoArgs,
+		// Runtime errors are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sources := processing.WorkspaceSources()
+			if len(sources) == 0 {
+				return fmt.Errorf("no source directories configured")
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SOURCE\tDIRECTORY\tREF\tCOMMIT\tSTATUS\tLFS")
+
+			var dirty []*git.State
+			for _, s := range sources {
+				state, err := processing.ReadWorkspaceState(ctx, s)
+				if err != nil {
+					fmt.Fprintf(w, "%s\t%s\t-\t-\t%v\t-\n", s.Name, s.Dir, err)
+					continue
+				}
+
+				status := "clean"
+				if state.DirtyCount > 0 {
+					status = fmt.Sprintf("%d dirty", state.DirtyCount)
+					dirty = append(dirty, state)
+				}
+
+				lfs := "-"
+				if state.LfsError != "" {
+					lfs = state.LfsError
+				} else if state.Lfs {
+					lfs = fmt.Sprintf("%d/%d fetched", state.LfsObjectsFetched, state.LfsObjects)
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s %s\t%.10s\t%s\t%s\n", s.Name, s.Dir, state.RefKind, state.Ref, state.Commit, status, lfs)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			// List the modified and untracked files of the dirty directories
+			for _, state := range dirty {
+				fmt.Fprintf(cmd.OutOrStdout(), "\n%s:\n", state.Dir)
+				for _, f := range state.Dirty {
+					fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", f)
+				}
+				if state.DirtyCount > len(state.Dirty) {
+					fmt.Fprintf(cmd.OutOrStdout(), "  ... and %d more\n", state.DirtyCount-len(state.Dirty))
+				}
+			}
+
+			return nil
+		},
+	}
+
+	//endregion
+
+	//region Sync
+
+	var syncSource, syncTarget, syncPlatform string
+	syncCmd := &cobra.Command{
+		Use:   "sync <ref>",
+		Short: "Clean the source directory and check out a tag, branch or commit the same way the jobs do",
+		Args:  cobra.ExactArgs(1),
+		// Runtime errors are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := processing.FindWorkspaceSource(syncSource)
+			if err != nil {
+				return err
+			}
+
+			workspace, err := processing.SyncWorkspace(ctx, source, args[0], syncTarget, syncPlatform)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s, checked out %s %s at %s\n", source.Dir, formatWorkspace(workspace), workspace.RefKind, workspace.Ref, workspace.Commit)
+			return nil
+		},
+	}
+	syncCmd.Flags().StringVar(&syncSource, "source", "project", "source directory to sync, e.g. project or launcher")
+	syncCmd.Flags().StringVar(&syncTarget, "target", "", "job target selecting the LFS filter of the project, e.g. Server")
+	syncCmd.Flags().StringVar(&syncPlatform, "platform", "", "job platform selecting the LFS filter of the project, e.g. Linux")
+
+	//endregion
+
+	//region Clean
+
+	var cleanSource string
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Reset the source directory and remove the untracked and ignored files except the kept ones",
+		Args:  cobra.NoArgs,
+		// Runtime errors are not usage errors
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := processing.FindWorkspaceSource(cleanSource)
+			if err != nil {
+				return err
+			}
+
+			workspace, err := processing.CleanWorkspace(ctx, source)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s, kept %v\n", source.Dir, formatWorkspace(workspace), workspace.Kept)
+			return nil
+		},
+	}
+	cleanCmd.Flags().StringVar(&cleanSource, "source", "project", "source directory to clean, e.g. project or launcher")
+
+	//endregion
+
+	workspaceCmd.AddCommand(statusCmd, syncCmd, cleanCmd)
+	return workspaceCmd
+}